@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/internal/database"
+	"github.com/malakagl/kart-challenge/pkg/constants"
+	logging "github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+	"github.com/malakagl/kart-challenge/pkg/services"
+)
+
+// apikey issues the first admin key, further keys are managed through /admin/api-keys.
+//
+//	apikey --config ./config/config.local.yaml --name ops --scopes admin create
+func main() {
+	var cfgPath, name, scopes string
+	flag.StringVar(&cfgPath, "config", "config.yaml", "path to YAML config file")
+	flag.StringVar(&name, "name", "", "name of the key")
+	flag.StringVar(&scopes, "scopes", "admin", "comma separated scopes of the key")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] create\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) != "create" || name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(cfgPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	logging.Init("api-key", cfg.Logging)
+	ctx := context.WithValue(context.Background(), constants.ClientIDKey, "cli")
+	db, err := database.Connect(ctx, &cfg.Database)
+	if err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	// the server caches lookups of existing keys only, a new key needs no invalidation
+	apiKeyService := services.NewAPIKeyService(repositories.NewUnitOfWork(db), repositories.NewAPIKeyRepo(db), nil)
+	req := &request.APIKeyRequest{Name: name, Scopes: strings.Split(scopes, ",")}
+	if err := validator.New().Struct(req); err != nil {
+		log.Fatalf("invalid api key: %v", err)
+	}
+
+	key, err := apiKeyService.Create(ctx, req)
+	if err != nil {
+		log.Fatalf("failed to create api key: %v", err)
+	}
+
+	fmt.Printf("%s (%s): %s\n", key.Name, key.ID, key.Key)
+}
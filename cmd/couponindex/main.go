@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/internal/couponcode"
+	logging "github.com/malakagl/kart-challenge/pkg/log"
+)
+
+// couponindex builds or verifies the on-disk coupon code index offline, so it can be
+// shipped next to the application instead of being rebuilt on every start.
+//
+//	couponindex --config ./config/config.local.yaml build
+//	couponindex --config ./config/config.local.yaml verify
+func main() {
+	var cfgPath, indexPath string
+	flag.StringVar(&cfgPath, "config", "config.yaml", "path to YAML config file")
+	flag.StringVar(&indexPath, "index", "", "index file path (defaults to couponCode.indexPath)")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] build|verify\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.LoadConfig(cfgPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	logging.Init("coupon-index", cfg.Logging)
+	if indexPath == "" {
+		indexPath = cfg.CouponCode.IndexPath
+	}
+	if indexPath == "" {
+		log.Fatal("index path not set, use --index or couponCode.indexPath")
+	}
+
+	ctx := context.Background()
+	switch flag.Arg(0) {
+	case "build":
+		idx, err := couponcode.BuildIndex(ctx, cfg.CouponCode.FilePaths)
+		if err != nil {
+			log.Fatalf("failed to build coupon index: %v", err)
+		}
+		if err := couponcode.WriteIndexFile(indexPath, idx); err != nil {
+			log.Fatalf("failed to write coupon index %s: %v", indexPath, err)
+		}
+		printStats(indexPath, idx.Stats())
+	case "verify":
+		idx, err := couponcode.VerifyIndexFile(ctx, indexPath, cfg.CouponCode.FilePaths)
+		if err != nil {
+			log.Fatalf("coupon index %s is not valid: %v", indexPath, err)
+		}
+		printStats(indexPath, idx.Stats())
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printStats(indexPath string, stats couponcode.IndexStats) {
+	fmt.Printf("%s: %d codes, %d bytes, loaded in %s\n", indexPath, stats.TotalCodes, stats.MemoryBytes, stats.LoadDuration)
+	for _, f := range stats.Files {
+		fmt.Printf("  %s: %d lines, %d codes, %d skipped\n", f.Path, f.Lines, f.Codes, f.Skipped)
+	}
+}
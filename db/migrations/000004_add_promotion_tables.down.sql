@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS coupon_code;
+DROP TABLE IF EXISTS promotion_rules;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,30 @@
+-- Promotions attach discount rules to a coupon code
+CREATE TABLE promotions
+(
+    id          SERIAL PRIMARY KEY,
+    coupon_code VARCHAR(10)  NOT NULL UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    active      BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE promotion_rules
+(
+    id            SERIAL PRIMARY KEY,
+    promotion_id  INT           NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    type          VARCHAR(32)   NOT NULL CHECK (type IN ('percentage_off', 'fixed_amount_off', 'buy_x_get_y',
+                                                         'free_cheapest_item', 'category_discount')),
+    percentage    DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    amount        DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    product_id    INT REFERENCES products (id) ON DELETE CASCADE,
+    buy_quantity  INT           NOT NULL DEFAULT 0,
+    free_quantity INT           NOT NULL DEFAULT 0,
+    min_quantity  INT           NOT NULL DEFAULT 0,
+    category      VARCHAR(255)  NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_promotion_rules_promotion_id ON promotion_rules (promotion_id);
+
+-- Keep track of the coupon code applied to an order
+ALTER TABLE orders ADD COLUMN coupon_code VARCHAR(10);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS coupon_redemptions;
+
+ALTER TABLE promotions
+    DROP COLUMN IF EXISTS valid_from,
+    DROP COLUMN IF EXISTS valid_to,
+    DROP COLUMN IF EXISTS max_redemptions,
+    DROP COLUMN IF EXISTS max_redemptions_per_customer,
+    DROP COLUMN IF EXISTS min_order_value;
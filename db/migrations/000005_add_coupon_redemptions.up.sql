@@ -0,0 +1,19 @@
+-- Coupon metadata lives on the promotion attached to the coupon code, NULL means unlimited
+ALTER TABLE promotions
+    ADD COLUMN valid_from                   TIMESTAMPTZ,
+    ADD COLUMN valid_to                     TIMESTAMPTZ,
+    ADD COLUMN max_redemptions              INT,
+    ADD COLUMN max_redemptions_per_customer INT,
+    ADD COLUMN min_order_value              DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+-- Every order placed with a coupon code records a redemption
+CREATE TABLE coupon_redemptions
+(
+    id          SERIAL PRIMARY KEY,
+    coupon_code VARCHAR(10) NOT NULL,
+    order_id    UUID        NOT NULL UNIQUE REFERENCES orders (id) ON DELETE CASCADE,
+    customer_id VARCHAR(64) NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_coupon_redemptions_code_customer ON coupon_redemptions (coupon_code, customer_id);
@@ -0,0 +1,17 @@
+ALTER TABLE orders
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'confirmed', 'preparing', 'ready', 'completed', 'cancelled', 'refunded'));
+
+-- Every status change of an order, the first row of an order has an empty from_status
+CREATE TABLE order_status_history
+(
+    id          SERIAL PRIMARY KEY,
+    order_id    UUID         NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status VARCHAR(20)  NOT NULL DEFAULT '',
+    to_status   VARCHAR(20)  NOT NULL,
+    changed_by  VARCHAR(64)  NOT NULL,
+    reason      VARCHAR(255) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_status_history_order_id ON order_status_history (order_id);
@@ -0,0 +1,15 @@
+-- Responses of POST requests sent with an Idempotency-Key header, status_code is NULL
+-- while the first request with the key is still being processed
+CREATE TABLE idempotency_keys
+(
+    client_id     VARCHAR(64)  NOT NULL,
+    key           VARCHAR(255) NOT NULL,
+    request_hash  CHAR(64)     NOT NULL,
+    status_code   INT,
+    response_body BYTEA,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (client_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
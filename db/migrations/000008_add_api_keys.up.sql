@@ -0,0 +1,18 @@
+-- API keys are stored as the hex SHA-256 of the key, scopes is a space separated list
+CREATE TABLE api_keys
+(
+    id           UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    name         VARCHAR(100) NOT NULL,
+    key_hash     CHAR(64)     NOT NULL UNIQUE,
+    prefix       VARCHAR(12)  NOT NULL,
+    scopes       VARCHAR(255) NOT NULL,
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- the documented demo key keeps working, revoke it in production
+INSERT INTO api_keys (name, key_hash, prefix, scopes)
+VALUES ('apitest', encode(sha256('apitest'::bytea), 'hex'), 'apitest',
+        'read_products create_order read_orders update_orders');
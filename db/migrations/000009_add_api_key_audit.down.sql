@@ -0,0 +1,4 @@
+ALTER TABLE api_keys
+    DROP COLUMN IF EXISTS replaced_by;
+
+DROP TABLE IF EXISTS api_key_audit;
@@ -0,0 +1,15 @@
+-- Every change made through the API key admin endpoints, actor_id is the API key used
+CREATE TABLE api_key_audit
+(
+    id         BIGSERIAL PRIMARY KEY,
+    api_key_id UUID         NOT NULL REFERENCES api_keys (id) ON DELETE CASCADE,
+    action     VARCHAR(20)  NOT NULL CHECK (action IN ('created', 'rotated', 'revoked')),
+    actor_id   VARCHAR(64)  NOT NULL,
+    details    VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_key_audit_api_key_id ON api_key_audit (api_key_id);
+
+ALTER TABLE api_keys
+    ADD COLUMN replaced_by UUID REFERENCES api_keys (id);
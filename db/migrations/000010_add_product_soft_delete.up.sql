@@ -0,0 +1,6 @@
+-- Products are soft deleted so orders placed before the deletion still resolve them
+ALTER TABLE products
+    ADD COLUMN updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_products_deleted_at ON products (deleted_at);
@@ -0,0 +1,10 @@
+UPDATE products p
+SET category = b.category
+FROM products_category_before_merge b
+WHERE b.product_id = p.id;
+DROP TABLE IF EXISTS products_category_before_merge;
+
+DROP INDEX IF EXISTS idx_products_category_id;
+ALTER TABLE products
+    DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,38 @@
+-- Categories are a resource of their own, products reference them instead of a free text name
+CREATE TABLE categories
+(
+    id            SERIAL PRIMARY KEY,
+    name          VARCHAR(255) NOT NULL UNIQUE,
+    slug          VARCHAR(255) NOT NULL UNIQUE,
+    display_order INT          NOT NULL DEFAULT 0,
+    active        BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Backfill from the category names already used by products. Names that only differ in
+-- case or punctuation share a slug and are merged into one category.
+INSERT INTO categories (name, slug, display_order)
+SELECT MIN(category), slug, ROW_NUMBER() OVER (ORDER BY MIN(category))
+FROM (SELECT category, TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(category), '[^a-z0-9]+', '-', 'g')) AS slug
+      FROM products) p
+GROUP BY slug;
+
+ALTER TABLE products
+    ADD COLUMN category_id INT REFERENCES categories (id);
+
+-- Keep the names as written so the down migration can restore them
+CREATE TABLE products_category_before_merge AS
+SELECT id AS product_id, category
+FROM products;
+
+-- Products of merged categories take the name of the category
+UPDATE products p
+SET category_id = c.id,
+    category    = c.name
+FROM categories c
+WHERE c.slug = TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(p.category), '[^a-z0-9]+', '-', 'g'));
+
+ALTER TABLE products
+    ALTER COLUMN category_id SET NOT NULL;
+
+CREATE INDEX idx_products_category_id ON products (category_id);
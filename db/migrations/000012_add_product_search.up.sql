@@ -0,0 +1,14 @@
+-- Full text search over product names and categories, names rank above categories. The
+-- simple configuration does not stem, so prefix queries match what the user typed.
+ALTER TABLE products
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        SETWEIGHT(TO_TSVECTOR('simple', COALESCE(name, '')), 'A') ||
+        SETWEIGHT(TO_TSVECTOR('simple', COALESCE(category, '')), 'B')
+        ) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+
+-- Trigram index for fuzzy matching of misspelt search terms
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_products_search_trgm ON products USING GIN ((name || ' ' || category) gin_trgm_ops);
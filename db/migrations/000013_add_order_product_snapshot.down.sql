@@ -0,0 +1,3 @@
+ALTER TABLE order_products
+    DROP COLUMN IF EXISTS unit_price,
+    DROP COLUMN IF EXISTS name;
@@ -0,0 +1,14 @@
+-- Orders keep the name and price their products had when they were placed, so later
+-- product changes do not rewrite past orders
+ALTER TABLE order_products
+    ADD COLUMN name VARCHAR(255),
+    ADD COLUMN unit_price DECIMAL(10, 2);
+
+UPDATE order_products op
+SET name = p.name, unit_price = p.price
+FROM products p
+WHERE p.id = op.product_id;
+
+ALTER TABLE order_products
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN unit_price SET NOT NULL;
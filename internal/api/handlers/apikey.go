@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	errors2 "errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService services.IAPIKeyService
+	validator     *validator.Validate
+}
+
+func NewAPIKeyHandler(s services.IAPIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: s,
+		validator:     validator.New(),
+	}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "APIKeyHandler.CreateAPIKey")
+	defer span.End()
+
+	var req request.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error decoding request body: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	key, err := h.apiKeyService.Create(ctx, &req)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating api key: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Error creating api key", err.Error())
+		return
+	}
+
+	response.Success(w, key)
+}
+
+func (h *APIKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "APIKeyHandler.ListAPIKeys")
+	defer span.End()
+
+	keys, err := h.apiKeyService.FindAll(ctx)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error listing api keys: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Error listing api keys", err.Error())
+		return
+	}
+
+	response.Success(w, keys)
+}
+
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "APIKeyHandler.RotateAPIKey")
+	defer span.End()
+
+	var req request.APIKeyRotateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors2.Is(err, io.EOF) {
+		log.WithCtx(ctx).Error().Msgf("Error decoding request body: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	rotation, err := h.apiKeyService.Rotate(ctx, chi.URLParam(r, "apiKeyID"), &req)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error rotating api key: %v", err)
+		writeAPIKeyError(w, err, "Error rotating api key")
+		return
+	}
+
+	response.Success(w, rotation)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "APIKeyHandler.RevokeAPIKey")
+	defer span.End()
+
+	key, err := h.apiKeyService.Revoke(ctx, chi.URLParam(r, "apiKeyID"))
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error revoking api key: %v", err)
+		writeAPIKeyError(w, err, "Error revoking api key")
+		return
+	}
+
+	response.Success(w, key)
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error, internalType string) {
+	switch {
+	case errors2.Is(err, errors.ErrInvalidAPIKeyID):
+		response.Error(w, http.StatusBadRequest, "Invalid api key ID", err.Error())
+	case errors2.Is(err, errors.ErrInvalidRotationOverlap):
+		response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+	case errors2.Is(err, errors.ErrAPIKeyNotFound):
+		response.Error(w, http.StatusNotFound, "API key not found", err.Error())
+	case errors2.Is(err, errors.ErrAPIKeyRevoked), errors2.Is(err, errors.ErrAPIKeyRotated),
+		errors2.Is(err, errors.ErrAPIKeyExpired):
+		response.Error(w, http.StatusConflict, "API key not active", err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, internalType, err.Error())
+	}
+}
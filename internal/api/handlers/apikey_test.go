@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	errors2 "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyService implements APIKeyService for testing
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) Create(_ context.Context, _ *request.APIKeyRequest) (*response.APIKey, error) {
+	args := m.Called()
+	return args.Get(0).(*response.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) FindAll(_ context.Context) (*response.APIKeysResponse, error) {
+	args := m.Called()
+	return args.Get(0).(*response.APIKeysResponse), args.Error(1)
+}
+
+func (m *MockAPIKeyService) Rotate(_ context.Context, id string, req *request.APIKeyRotateRequest) (*response.APIKeyRotation, error) {
+	args := m.Called(id, req.Overlap)
+	return args.Get(0).(*response.APIKeyRotation), args.Error(1)
+}
+
+func (m *MockAPIKeyService) Revoke(_ context.Context, id string) (*response.APIKey, error) {
+	args := m.Called(id)
+	return args.Get(0).(*response.APIKey), args.Error(1)
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           interface{}
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "successful request",
+			body:           request.APIKeyRequest{Name: "pos", Scopes: []string{"create_order"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid JSON",
+			body:           "{invalid-json}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown scope",
+			body:           request.APIKeyRequest{Name: "pos", Scopes: []string{"everything"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "no scopes",
+			body:           request.APIKeyRequest{Name: "pos"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "service error",
+			body:           request.APIKeyRequest{Name: "pos", Scopes: []string{"admin"}},
+			mockErr:        errors2.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			if s, ok := tt.body.(string); ok {
+				body = []byte(s)
+			} else {
+				body, _ = json.Marshal(tt.body)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockAPIKeyService)
+			mockService.On("Create").Return(&response.APIKey{ID: "1", Key: "kart_secret"}, tt.mockErr)
+			NewAPIKeyHandler(mockService).CreateAPIKey(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	id := "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d"
+	tests := []struct {
+		name            string
+		body            string
+		expectedOverlap string
+		mockErr         error
+		expectedStatus  int
+	}{
+		{name: "default overlap", expectedStatus: http.StatusOK},
+		{name: "custom overlap", body: `{"overlap":"1h"}`, expectedOverlap: "1h", expectedStatus: http.StatusOK},
+		{name: "invalid JSON", body: "{", expectedStatus: http.StatusBadRequest},
+		{name: "invalid overlap", body: `{"overlap":"forever"}`, expectedOverlap: "forever",
+			mockErr: errors.ErrInvalidRotationOverlap, expectedStatus: http.StatusBadRequest},
+		{name: "not found", mockErr: errors.ErrAPIKeyNotFound, expectedStatus: http.StatusNotFound},
+		{name: "revoked", mockErr: errors.ErrAPIKeyRevoked, expectedStatus: http.StatusConflict},
+		{name: "already rotated", mockErr: errors.ErrAPIKeyRotated, expectedStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := chi.NewRouteContext()
+			ctx.URLParams.Add("apiKeyID", id)
+			req := httptest.NewRequest(http.MethodPost, "/admin/api-keys/"+id+"/rotate", bytes.NewBufferString(tt.body))
+			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, ctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockAPIKeyService)
+			mockService.On("Rotate", id, tt.expectedOverlap).Return(&response.APIKeyRotation{}, tt.mockErr)
+			NewAPIKeyHandler(mockService).RotateAPIKey(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		mockErr        error
+		expectedStatus int
+	}{
+		{name: "successful request", id: "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d", expectedStatus: http.StatusOK},
+		{name: "invalid id", id: "not-a-uuid", mockErr: errors.ErrInvalidAPIKeyID, expectedStatus: http.StatusBadRequest},
+		{name: "not found", id: "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d", mockErr: errors.ErrAPIKeyNotFound, expectedStatus: http.StatusNotFound},
+		{name: "service error", id: "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d", mockErr: errors.ErrInternalServerError, expectedStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := chi.NewRouteContext()
+			ctx.URLParams.Add("apiKeyID", tt.id)
+			req := httptest.NewRequest(http.MethodDelete, "/admin/api-keys/"+tt.id, nil)
+			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, ctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockAPIKeyService)
+			mockService.On("Revoke", tt.id).Return(&response.APIKey{}, tt.mockErr)
+			NewAPIKeyHandler(mockService).RevokeAPIKey(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	errors2 "errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/services"
+)
+
+type CategoryHandler struct {
+	service   services.ICategoryService
+	validator *validator.Validate
+}
+
+func NewCategoryHandler(s services.ICategoryService) *CategoryHandler {
+	return &CategoryHandler{service: s, validator: validator.New()}
+}
+
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "CategoryHandler.ListCategories")
+	defer span.End()
+
+	categories, err := h.service.FindAll(ctx)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching categories: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Error fetching categories", "Error fetching categories")
+		return
+	}
+
+	response.Success(w, categories)
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "CategoryHandler.CreateCategory")
+	defer span.End()
+
+	var req request.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error decoding request body: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	category, err := h.service.Create(ctx, &req)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating category: %v", err)
+		switch {
+		case errors2.Is(err, errors.ErrInvalidCategoryName):
+			response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+		case errors2.Is(err, errors.ErrCategoryExists):
+			response.Error(w, http.StatusConflict, "Category already exists", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Error creating category", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, category)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCategoryService struct {
+	mock.Mock
+}
+
+func (m *MockCategoryService) FindAll(_ context.Context) (*response.CategoriesResponse, error) {
+	args := m.Called()
+	return args.Get(0).(*response.CategoriesResponse), args.Error(1)
+}
+
+func (m *MockCategoryService) Create(_ context.Context, req *request.CategoryRequest) (*response.Category, error) {
+	args := m.Called(req)
+	return args.Get(0).(*response.Category), args.Error(1)
+}
+
+func TestListCategories(t *testing.T) {
+	mockService := new(MockCategoryService)
+	mockService.On("FindAll").Return(&response.CategoriesResponse{Categories: []response.Category{
+		{ID: "1", Name: "Waffle", Slug: "waffle", DisplayOrder: 1},
+		{ID: "2", Name: "Crème Brûlée", Slug: "cr-me-br-l-e", DisplayOrder: 2},
+	}}, nil)
+
+	w := httptest.NewRecorder()
+	NewCategoryHandler(mockService).ListCategories(w, httptest.NewRequest(http.MethodGet, "/category", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var body struct {
+		Data response.CategoriesResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(body.Data.Categories) != 2 || body.Data.Categories[0].Slug != "waffle" {
+		t.Errorf("expected the categories in order, got %+v", body.Data.Categories)
+	}
+}
+
+func TestListCategories_Error(t *testing.T) {
+	mockService := new(MockCategoryService)
+	mockService.On("FindAll").Return((*response.CategoriesResponse)(nil), errors.ErrDatabaseError)
+
+	w := httptest.NewRecorder()
+	NewCategoryHandler(mockService).ListCategories(w, httptest.NewRequest(http.MethodGet, "/category", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+}
+
+func TestCreateCategory(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{name: "created", body: `{"name":"Pies & Tarts","displayOrder":3}`, wantStatus: http.StatusOK},
+		{name: "missing name", body: `{"displayOrder":3}`, wantStatus: http.StatusBadRequest},
+		{name: "negative display order", body: `{"name":"Pies","displayOrder":-1}`, wantStatus: http.StatusBadRequest},
+		{name: "name without slug", body: `{"name":"!!!"}`, err: errors.ErrInvalidCategoryName, wantStatus: http.StatusBadRequest},
+		{name: "duplicate", body: `{"name":"WAFFLE"}`, err: errors.ErrCategoryExists, wantStatus: http.StatusConflict},
+		{name: "database error", body: `{"name":"Pies"}`, err: errors.ErrDatabaseError, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCategoryService)
+			if tt.err != nil {
+				mockService.On("Create", mock.Anything).Return((*response.Category)(nil), tt.err)
+			} else {
+				mockService.On("Create", &request.CategoryRequest{Name: "Pies & Tarts", DisplayOrder: 3}).
+					Return(&response.Category{ID: "5", Name: "Pies & Tarts", Slug: "pies-tarts", DisplayOrder: 3}, nil)
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/category", strings.NewReader(tt.body))
+			NewCategoryHandler(mockService).CreateCategory(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
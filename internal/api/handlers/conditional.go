@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// notModified sets the ETag and Last-Modified validators of a GET response and writes a
+// 304 Not Modified when the conditional headers of the request show the client already
+// has it. The ETag is a hash of v, the data of the response. If-Modified-Since is only
+// looked at when there is no If-None-Match, as RFC 9110 asks. Clients are told to
+// revalidate before reusing a response.
+func notModified(w http.ResponseWriter, r *http.Request, lastModified time.Time, v ...any) bool {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		// Last-Modified has a resolution of a second
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches tells whether the If-None-Match header lists etag, comparing weakly.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/malakagl/kart-challenge/internal/health"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+)
+
+type healthDetails struct {
+	Liveness  *health.Report `json:"liveness"`
+	Readiness *health.Report `json:"readiness"`
+}
+
+type HealthHandler struct {
+	liveness  *health.Registry
+	readiness *health.Registry
+}
+
+func NewHealthHandler(liveness, readiness *health.Registry) *HealthHandler {
+	return &HealthHandler{liveness: liveness, readiness: readiness}
+}
+
+// Livez tells whether the process works, it fails when it should be restarted.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, r, h.liveness.Check(r.Context()))
+}
+
+// Readyz tells whether the service can take requests, it fails while dependencies are
+// down and while the server shuts down.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, r, h.readiness.Check(r.Context()))
+}
+
+// Details returns the result of every check, only admins may see it as errors can name
+// hosts and paths.
+func (h *HealthHandler) Details(w http.ResponseWriter, r *http.Request) {
+	live, ready := h.liveness.Check(r.Context()), h.readiness.Check(r.Context())
+	code := http.StatusOK
+	if !live.Healthy() || !ready.Healthy() {
+		code = http.StatusServiceUnavailable
+	}
+
+	response.JSON(w, code, response.APIResponse{
+		Code:    code,
+		Type:    "Health",
+		Message: http.StatusText(code),
+		Data:    healthDetails{Liveness: live, Readiness: ready},
+	})
+}
+
+// writeProbe answers probes with the names of the failing checks only.
+func writeProbe(w http.ResponseWriter, r *http.Request, report *health.Report) {
+	if report.Healthy() {
+		response.Success(w, health.StatusOK)
+		return
+	}
+
+	var failing []string
+	for name, res := range report.Checks {
+		if res.Status != health.StatusOK {
+			failing = append(failing, name)
+		}
+	}
+	slices.Sort(failing)
+
+	log.WithCtx(r.Context()).Warn().Msgf("Health check %s failing: %s", r.URL.Path, strings.Join(failing, ", "))
+	response.Error(w, http.StatusServiceUnavailable, "HealthError", "failing checks: "+strings.Join(failing, ", "))
+}
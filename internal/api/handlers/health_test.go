@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/internal/health"
+)
+
+func TestHealthHandler(t *testing.T) {
+	liveness, readiness := health.NewRegistry(0), health.NewRegistry(0)
+	var dbErr error
+	readiness.Register("database", 0, func(context.Context) error { return dbErr })
+	h := NewHealthHandler(liveness, readiness)
+
+	w := httptest.NewRecorder()
+	h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+
+	dbErr = errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	w = httptest.NewRecorder()
+	h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "database") {
+		t.Errorf("expected 503 naming the check, got %d %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "10.0.0.5") {
+		t.Errorf("expected probes not to expose check errors, got %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.Livez(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected liveness to ignore dependencies, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.Details(w, httptest.NewRequest(http.MethodGet, "/admin/health", nil))
+	var res struct {
+		Data healthDetails `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable || res.Data.Readiness.Checks["database"].Error != dbErr.Error() {
+		t.Errorf("expected the detailed report, got %d %+v", w.Code, res.Data.Readiness)
+	}
+}
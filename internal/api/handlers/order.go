@@ -2,14 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
+	errors2 "errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
-	errors2 "github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/errors"
 	"github.com/malakagl/kart-challenge/pkg/log"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/money"
 	"github.com/malakagl/kart-challenge/pkg/services"
 )
 
@@ -26,7 +32,9 @@ func NewOrderHandler(o services.IOrderService) *OrderHandler {
 }
 
 func (o *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx, span := tracing.Start(r.Context(), "OrderHandler.CreateOrder")
+	defer span.End()
+
 	var orderReq request.OrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&orderReq); err != nil {
 		log.WithCtx(ctx).Error().Msgf("Error decoding request body: %v", err)
@@ -43,9 +51,22 @@ func (o *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	orderRes, err := o.orderService.Create(ctx, &orderReq)
 	if err != nil {
 		log.WithCtx(ctx).Error().Msgf("Error creating order: %v", err)
-		if errors.Is(err, errors2.ErrInvalidCouponCode) {
+		switch {
+		case errors2.Is(err, errors.ErrInvalidProductID):
+			response.Error(w, http.StatusBadRequest, "Invalid product ID", err.Error())
+		case errors2.Is(err, errors.ErrProductNotFound):
+			response.Error(w, http.StatusUnprocessableEntity, "Product not found", err.Error())
+		case errors2.Is(err, errors.ErrInvalidCouponCode):
 			response.Error(w, http.StatusUnprocessableEntity, "Invalid coupon code", err.Error())
-		} else {
+		case errors2.Is(err, errors.ErrCouponExpired):
+			response.Error(w, http.StatusUnprocessableEntity, "Coupon code expired", err.Error())
+		case errors2.Is(err, errors.ErrCouponNotYetValid):
+			response.Error(w, http.StatusUnprocessableEntity, "Coupon code not yet valid", err.Error())
+		case errors2.Is(err, errors.ErrCouponExhausted):
+			response.Error(w, http.StatusUnprocessableEntity, "Coupon code exhausted", err.Error())
+		case errors2.Is(err, errors.ErrCouponBelowMinimum):
+			response.Error(w, http.StatusUnprocessableEntity, "Coupon code below minimum order value", err.Error())
+		default:
 			response.Error(w, http.StatusInternalServerError, "Failed to create order", err.Error())
 		}
 		return
@@ -53,3 +74,152 @@ func (o *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 	response.Success(w, orderRes)
 }
+
+func (o *OrderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "OrderHandler.GetOrderByID")
+	defer span.End()
+
+	orderRes, err := o.orderService.FindByID(ctx, chi.URLParam(r, "orderID"))
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching order: %v", err)
+		switch {
+		case errors2.Is(err, errors.ErrInvalidOrderID):
+			response.Error(w, http.StatusBadRequest, "Invalid order ID", err.Error())
+		case errors2.Is(err, errors.ErrOrderNotFound):
+			response.Error(w, http.StatusNotFound, "Order not found", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Error fetching order", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, orderRes)
+}
+
+func (o *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "OrderHandler.UpdateOrderStatus")
+	defer span.End()
+
+	var statusReq request.OrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&statusReq); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error decoding request body: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := o.validator.Struct(statusReq); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+		return
+	}
+
+	orderRes, err := o.orderService.UpdateStatus(ctx, chi.URLParam(r, "orderID"), &statusReq)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error updating order status: %v", err)
+		switch {
+		case errors2.Is(err, errors.ErrInvalidOrderID):
+			response.Error(w, http.StatusBadRequest, "Invalid order ID", err.Error())
+		case errors2.Is(err, errors.ErrOrderNotFound):
+			response.Error(w, http.StatusNotFound, "Order not found", err.Error())
+		case errors2.Is(err, errors.ErrInvalidStatusTransition):
+			response.Error(w, http.StatusConflict, "Invalid status transition", err.Error())
+		default:
+			response.Error(w, http.StatusInternalServerError, "Error updating order status", err.Error())
+		}
+		return
+	}
+
+	response.Success(w, orderRes)
+}
+
+func (o *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "OrderHandler.ListOrders")
+	defer span.End()
+
+	listReq, err := parseOrderListRequest(r)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid order list query: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+		return
+	}
+
+	if err := o.validator.Struct(listReq); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+		return
+	}
+
+	orders, page, err := o.orderService.FindAll(ctx, listReq)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error listing orders: %v", err)
+		if errors2.Is(err, errors.ErrInvalidCursor) {
+			response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+			return
+		}
+
+		response.Error(w, http.StatusInternalServerError, "Error listing orders", err.Error())
+		return
+	}
+
+	response.SuccessWithPagination(w, orders, page)
+}
+
+// parseOrderListRequest reads the filters of GET /order from the query string.
+// Times are RFC 3339, totals are decimal numbers.
+func parseOrderListRequest(r *http.Request) (*request.OrderListRequest, error) {
+	q := r.URL.Query()
+	req := &request.OrderListRequest{
+		CouponCode: q.Get("couponCode"),
+		Cursor:     q.Get("cursor"),
+	}
+
+	var err error
+	if req.CreatedFrom, err = parseTimeParam(q.Get("createdFrom")); err != nil {
+		return nil, fmt.Errorf("createdFrom: %w", err)
+	}
+	if req.CreatedTo, err = parseTimeParam(q.Get("createdTo")); err != nil {
+		return nil, fmt.Errorf("createdTo: %w", err)
+	}
+	if req.MinTotal, err = parseMoneyParam(q.Get("minTotal")); err != nil {
+		return nil, fmt.Errorf("minTotal: %w", err)
+	}
+	if req.MaxTotal, err = parseMoneyParam(q.Get("maxTotal")); err != nil {
+		return nil, fmt.Errorf("maxTotal: %w", err)
+	}
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+func parseTimeParam(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func parseMoneyParam(v string) (*money.Money, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	m, err := money.Parse(v, money.DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	if m.IsNegative() {
+		return nil, fmt.Errorf("%s must not be negative", v)
+	}
+
+	return &m, nil
+}
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
+	errors2 "errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	errors2 "github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/pkg/errors"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/money"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -25,6 +27,21 @@ func (m *MockOrderService) Create(_ context.Context, _ *request.OrderRequest) (*
 	return args.Get(0).(*response.OrderResponse), args.Error(1)
 }
 
+func (m *MockOrderService) FindByID(_ context.Context, id string) (*response.OrderResponse, error) {
+	args := m.Called(id)
+	return args.Get(0).(*response.OrderResponse), args.Error(1)
+}
+
+func (m *MockOrderService) FindAll(_ context.Context, req *request.OrderListRequest) (*response.OrdersResponse, *response.Pagination, error) {
+	args := m.Called(req)
+	return args.Get(0).(*response.OrdersResponse), args.Get(1).(*response.Pagination), args.Error(2)
+}
+
+func (m *MockOrderService) UpdateStatus(_ context.Context, id string, req *request.OrderStatusRequest) (*response.OrderResponse, error) {
+	args := m.Called(id, req.Status)
+	return args.Get(0).(*response.OrderResponse), args.Error(1)
+}
+
 func TestCreateOrder(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -32,6 +49,7 @@ func TestCreateOrder(t *testing.T) {
 		mockRes        *response.OrderResponse
 		mockErr        error
 		expectedStatus int
+		expectedType   string
 	}{
 		{
 			name: "successful order",
@@ -39,7 +57,7 @@ func TestCreateOrder(t *testing.T) {
 				CouponCode: "HAPPYHRS",
 				Items:      []request.Item{{ProductID: "1", Quantity: 2}},
 			},
-			mockRes:        &response.OrderResponse{ID: "1234", Total: 100.0},
+			mockRes:        &response.OrderResponse{ID: "1234", Total: money.New(10000, money.USD)},
 			expectedStatus: http.StatusOK,
 		},
 		{
@@ -53,8 +71,48 @@ func TestCreateOrder(t *testing.T) {
 				CouponCode: "WRONGCODE",
 				Items:      []request.Item{{ProductID: "1", Quantity: 2}},
 			},
-			mockErr:        errors2.ErrInvalidCouponCode,
+			mockErr:        errors.ErrInvalidCouponCode,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "expired coupon code",
+			body: request.OrderRequest{
+				CouponCode: "HAPPYHRS",
+				Items:      []request.Item{{ProductID: "1", Quantity: 2}},
+			},
+			mockErr:        errors.ErrCouponExpired,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedType:   "Coupon code expired",
+		},
+		{
+			name: "exhausted coupon code",
+			body: request.OrderRequest{
+				CouponCode: "HAPPYHRS",
+				Items:      []request.Item{{ProductID: "1", Quantity: 2}},
+			},
+			mockErr:        errors.ErrCouponExhausted,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedType:   "Coupon code exhausted",
+		},
+		{
+			name: "coupon code below minimum order value",
+			body: request.OrderRequest{
+				CouponCode: "HAPPYHRS",
+				Items:      []request.Item{{ProductID: "1", Quantity: 2}},
+			},
+			mockErr:        errors.ErrCouponBelowMinimum,
 			expectedStatus: http.StatusUnprocessableEntity,
+			expectedType:   "Coupon code below minimum order value",
+		},
+		{
+			name: "unknown product",
+			body: request.OrderRequest{
+				CouponCode: "HAPPYHRS",
+				Items:      []request.Item{{ProductID: "999", Quantity: 1}},
+			},
+			mockErr:        errors.ErrProductNotFound,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedType:   "Product not found",
 		},
 		{
 			name: "invalid item count",
@@ -62,7 +120,7 @@ func TestCreateOrder(t *testing.T) {
 				CouponCode: "TESTCODE",
 				Items:      []request.Item{{ProductID: "1", Quantity: 0}},
 			},
-			mockErr:        errors2.ErrInvalidCouponCode,
+			mockErr:        errors.ErrInvalidCouponCode,
 			expectedStatus: http.StatusBadRequest,
 		},
 		{
@@ -71,7 +129,7 @@ func TestCreateOrder(t *testing.T) {
 				CouponCode: "HAPPYHRS",
 				Items:      []request.Item{{ProductID: "1", Quantity: 2}},
 			},
-			mockErr:        errors.New("db error"),
+			mockErr:        errors2.New("db error"),
 			expectedStatus: http.StatusInternalServerError,
 		},
 	}
@@ -94,6 +152,203 @@ func TestCreateOrder(t *testing.T) {
 			mockService.On("Create", mock.Anything).Return(tt.mockRes, tt.mockErr)
 			handler.CreateOrder(w, req)
 
+			resp := w.Result()
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedType != "" {
+				var apiResp response.APIResponse
+				if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if apiResp.Type != tt.expectedType {
+					t.Errorf("expected error type %q, got %q", tt.expectedType, apiResp.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestGetOrderByID(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		mockRes        *response.OrderResponse
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "successful request",
+			id:             "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d",
+			mockRes:        &response.OrderResponse{ID: "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid order id",
+			id:             "not-a-uuid",
+			mockErr:        errors.ErrInvalidOrderID,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "order not found",
+			id:             "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d",
+			mockErr:        errors.ErrOrderNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "service error",
+			id:             "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d",
+			mockErr:        errors2.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := chi.NewRouteContext()
+			ctx.URLParams.Add("orderID", tt.id)
+			req := httptest.NewRequest(http.MethodGet, "/order/"+tt.id, nil)
+			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, ctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockOrderService)
+			mockService.On("FindByID", tt.id).Return(tt.mockRes, tt.mockErr)
+			handler := NewOrderHandler(mockService)
+			handler.GetOrderByID(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		mockRes        *response.OrdersResponse
+		mockPage       *response.Pagination
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "successful request",
+			query:          "?createdFrom=2025-01-01T00:00:00Z&couponCode=HAPPYHRS&minTotal=10&maxTotal=99.5&limit=10",
+			mockRes:        &response.OrdersResponse{Orders: []response.OrderResponse{{ID: "1"}}},
+			mockPage:       &response.Pagination{Limit: 10, HasMore: true, NextCursor: "abc"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid time",
+			query:          "?createdTo=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid total",
+			query:          "?minTotal=ten",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative total",
+			query:          "?maxTotal=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "limit too large",
+			query:          "?limit=1000",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid cursor",
+			query:          "?cursor=garbage",
+			mockErr:        errors.ErrInvalidCursor,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "service error",
+			mockErr:        errors2.New("db error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/order"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockService := new(MockOrderService)
+			mockService.On("FindAll", mock.Anything).Return(tt.mockRes, tt.mockPage, tt.mockErr)
+			handler := NewOrderHandler(mockService)
+			handler.ListOrders(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.mockPage != nil {
+				var apiResp response.APIResponse
+				if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if apiResp.Pagination == nil || apiResp.Pagination.NextCursor != tt.mockPage.NextCursor {
+					t.Errorf("expected pagination %+v, got %+v", tt.mockPage, apiResp.Pagination)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	const orderID = "5b0f2a4e-4c35-4a8c-9a55-0e5f3b8f6a1d"
+	tests := []struct {
+		name           string
+		body           string
+		mockRes        *response.OrderResponse
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "successful transition",
+			body:           `{"status":"confirmed"}`,
+			mockRes:        &response.OrderResponse{ID: orderID, Status: "confirmed"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid JSON",
+			body:           "{invalid-json}",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown status",
+			body:           `{"status":"eaten"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid transition",
+			body:           `{"status":"pending"}`,
+			mockErr:        errors.ErrInvalidStatusTransition,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "order not found",
+			body:           `{"status":"confirmed"}`,
+			mockErr:        errors.ErrOrderNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := chi.NewRouteContext()
+			ctx.URLParams.Add("orderID", orderID)
+			req := httptest.NewRequest(http.MethodPatch, "/order/"+orderID+"/status", bytes.NewReader([]byte(tt.body)))
+			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, ctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockOrderService)
+			mockService.On("UpdateStatus", orderID, mock.Anything).Return(tt.mockRes, tt.mockErr)
+			handler := NewOrderHandler(mockService)
+			handler.UpdateOrderStatus(w, req)
+
 			resp := w.Result()
 			if resp.StatusCode != tt.expectedStatus {
 				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
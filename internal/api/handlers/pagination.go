@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+)
+
+// setPaginationLinks adds an RFC 8288 Link header with the first page and, when there is
+// one, the next page of a cursor paginated list. The links keep the other query
+// parameters of the request.
+func setPaginationLinks(w http.ResponseWriter, r *http.Request, page *response.Pagination) {
+	link := func(cursor, rel string) string {
+		q := r.URL.Query()
+		q.Del("cursor")
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+
+	w.Header().Add("Link", link("", "first"))
+	if page != nil && page.HasMore {
+		w.Header().Add("Link", link(page.NextCursor, "next"))
+	}
+}
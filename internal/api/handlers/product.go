@@ -1,31 +1,78 @@
 package handlers
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
+	errors2 "errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	errors2 "github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/errors"
 	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
 	"github.com/malakagl/kart-challenge/pkg/services"
 	"github.com/malakagl/kart-challenge/pkg/util"
 )
 
 type ProductHandler struct {
-	service services.IProductService
+	service   services.IProductService
+	validator *validator.Validate
 }
 
 func NewProductHandler(s services.IProductService) *ProductHandler {
-	return &ProductHandler{service: s}
+	return &ProductHandler{
+		service:   s,
+		validator: validator.New(),
+	}
 }
 
 func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	products, err := h.service.FindAll(ctx)
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.ListProducts")
+	defer span.End()
+
+	h.listProducts(ctx, w, r, "")
+}
+
+// ListCategoryProducts lists the products of the category in the slug path parameter,
+// with the same filters and pagination as ListProducts.
+func (h *ProductHandler) ListCategoryProducts(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.ListCategoryProducts")
+	defer span.End()
+
+	h.listProducts(ctx, w, r, chi.URLParam(r, "slug"))
+}
+
+func (h *ProductHandler) listProducts(ctx context.Context, w http.ResponseWriter, r *http.Request, categorySlug string) {
+	listReq, err := parseProductListRequest(r)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid product list query: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+		return
+	}
+
+	listReq.CategorySlug = categorySlug
+	if err := h.validator.Struct(listReq); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+		return
+	}
+
+	products, page, err := h.service.FindAll(ctx, listReq)
 	if err != nil {
 		log.WithCtx(ctx).Error().Msgf("Error fetching products: %v", err)
-		if errors.Is(err, errors2.ErrProductNotFound) {
+		switch {
+		case errors2.Is(err, errors.ErrInvalidCursor), errors2.Is(err, errors.ErrInvalidCategoryName):
+			response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+			return
+		case errors2.Is(err, errors.ErrCategoryNotFound):
+			response.Error(w, http.StatusNotFound, "Category not found", err.Error())
+			return
+		case errors2.Is(err, errors.ErrProductNotFound):
 			response.Error(w, http.StatusNotFound, "No products found", "No products available in the database")
 			return
 		}
@@ -34,23 +81,54 @@ func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response.Success(w, products)
+	setPaginationLinks(w, r, page)
+	if notModified(w, r, products.LastModified, products, page) {
+		return
+	}
+
+	response.SuccessWithPagination(w, products, page)
+}
+
+func (h *ProductHandler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.SearchProducts")
+	defer span.End()
+
+	searchReq, err := parseProductSearchRequest(r)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid product search query: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+		return
+	}
+
+	if err := h.validator.Struct(searchReq); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid query parameters", err.Error())
+		return
+	}
+
+	results, err := h.service.Search(ctx, searchReq)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error searching products: %v", err)
+		response.Error(w, http.StatusInternalServerError, "Error searching products", "Error searching products")
+		return
+	}
+
+	response.Success(w, results)
 }
 
 func (h *ProductHandler) GetProductByID(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	pID := chi.URLParam(r, "productID")
-	productId, err := util.StringToUint(pID)
-	if err != nil || productId == 0 {
-		log.WithCtx(ctx).Error().Msgf("Invalid product ID in order request: %s", pID)
-		response.Error(w, http.StatusBadRequest, "Invalid product ID", "Invalid product ID")
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.GetProductByID")
+	defer span.End()
+
+	productId, ok := productIDParam(ctx, w, r)
+	if !ok {
 		return
 	}
 
 	product, err := h.service.FindByID(ctx, productId)
 	if err != nil {
 		log.WithCtx(ctx).Error().Msgf("Error fetching product: %v", err)
-		if errors.Is(err, errors2.ErrProductNotFound) {
+		if errors2.Is(err, errors.ErrProductNotFound) {
 			response.Error(w, http.StatusNotFound, "No products found", "No products found in the database")
 			return
 		}
@@ -59,5 +137,191 @@ func (h *ProductHandler) GetProductByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if notModified(w, r, product.UpdatedAt, product) {
+		return
+	}
+
 	response.Success(w, product)
 }
+
+func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.CreateProduct")
+	defer span.End()
+
+	var req request.ProductRequest
+	if !h.decode(ctx, w, r, &req) {
+		return
+	}
+
+	product, err := h.service.Create(ctx, &req)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating product: %v", err)
+		writeProductError(w, err, "Error creating product")
+		return
+	}
+
+	response.Success(w, product)
+}
+
+func (h *ProductHandler) ReplaceProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.ReplaceProduct")
+	defer span.End()
+
+	productID, ok := productIDParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var req request.ProductRequest
+	if !h.decode(ctx, w, r, &req) {
+		return
+	}
+
+	product, err := h.service.Replace(ctx, productID, &req)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error replacing product: %v", err)
+		writeProductError(w, err, "Error updating product")
+		return
+	}
+
+	response.Success(w, product)
+}
+
+func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.UpdateProduct")
+	defer span.End()
+
+	productID, ok := productIDParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var req request.ProductPatchRequest
+	if !h.decode(ctx, w, r, &req) {
+		return
+	}
+
+	product, err := h.service.Update(ctx, productID, &req)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error updating product: %v", err)
+		writeProductError(w, err, "Error updating product")
+		return
+	}
+
+	response.Success(w, product)
+}
+
+func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Start(r.Context(), "ProductHandler.DeleteProduct")
+	defer span.End()
+
+	productID, ok := productIDParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	product, err := h.service.Delete(ctx, productID)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error deleting product: %v", err)
+		writeProductError(w, err, "Error deleting product")
+		return
+	}
+
+	response.Success(w, product)
+}
+
+// decode reads and validates the JSON body into req, writing a 400 response when it is
+// malformed or invalid.
+func (h *ProductHandler) decode(ctx context.Context, w http.ResponseWriter, r *http.Request, req any) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error decoding request body: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Validation error: %v", err)
+		response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// productIDParam reads the productID path parameter, writing a 400 response when it is
+// not a positive number.
+func productIDParam(ctx context.Context, w http.ResponseWriter, r *http.Request) (uint, bool) {
+	pID := chi.URLParam(r, "productID")
+	productID, err := util.StringToUint(pID)
+	if err != nil || productID == 0 {
+		log.WithCtx(ctx).Error().Msgf("Invalid product ID: %s", pID)
+		response.Error(w, http.StatusBadRequest, "Invalid product ID", "Invalid product ID")
+		return 0, false
+	}
+
+	return productID, true
+}
+
+func writeProductError(w http.ResponseWriter, err error, internalType string) {
+	switch {
+	case errors2.Is(err, errors.ErrInvalidProductPrice), errors2.Is(err, errors.ErrCategoryNotFound):
+		response.Error(w, http.StatusBadRequest, "Invalid request data", err.Error())
+	case errors2.Is(err, errors.ErrProductNotFound):
+		response.Error(w, http.StatusNotFound, "Product not found", err.Error())
+	default:
+		response.Error(w, http.StatusInternalServerError, internalType, err.Error())
+	}
+}
+
+// parseProductListRequest reads the filters of GET /product from the query string.
+// Prices are decimal numbers.
+func parseProductListRequest(r *http.Request) (*request.ProductListRequest, error) {
+	q := r.URL.Query()
+	req := &request.ProductListRequest{
+		Category: q.Get("category"),
+		Query:    q.Get("q"),
+		Sort:     q.Get("sort"),
+		Order:    q.Get("order"),
+		Cursor:   q.Get("cursor"),
+	}
+
+	var err error
+	if req.MinPrice, err = parseMoneyParam(q.Get("minPrice")); err != nil {
+		return nil, fmt.Errorf("minPrice: %w", err)
+	}
+	if req.MaxPrice, err = parseMoneyParam(q.Get("maxPrice")); err != nil {
+		return nil, fmt.Errorf("maxPrice: %w", err)
+	}
+	if req.MinPrice != nil && req.MaxPrice != nil {
+		if cmp, err := req.MinPrice.Cmp(*req.MaxPrice); err != nil || cmp > 0 {
+			return nil, errors2.New("minPrice must not be greater than maxPrice")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+// parseProductSearchRequest reads the query of GET /product/search from the query string.
+func parseProductSearchRequest(r *http.Request) (*request.ProductSearchRequest, error) {
+	q := r.URL.Query()
+	req := &request.ProductSearchRequest{Query: q.Get("q")}
+
+	var err error
+	if v := q.Get("fuzzy"); v != "" {
+		if req.Fuzzy, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("fuzzy: %w", err)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+	}
+
+	return req, nil
+}
@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	errors2 "errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	errors2 "github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,9 +23,14 @@ type MockProductService struct {
 	mock.Mock
 }
 
-func (m *MockProductService) FindAll(_ context.Context) (*response.ProductsResponse, error) {
-	args := m.Called()
-	return args.Get(0).(*response.ProductsResponse), args.Error(1)
+func (m *MockProductService) FindAll(_ context.Context, req *request.ProductListRequest) (*response.ProductsResponse, *response.Pagination, error) {
+	args := m.Called(req)
+	return args.Get(0).(*response.ProductsResponse), args.Get(1).(*response.Pagination), args.Error(2)
+}
+
+func (m *MockProductService) Search(_ context.Context, req *request.ProductSearchRequest) (*response.ProductSearchResponse, error) {
+	args := m.Called(req)
+	return args.Get(0).(*response.ProductSearchResponse), args.Error(1)
 }
 
 func (m *MockProductService) FindByID(_ context.Context, id uint) (*response.ProductResponse, error) {
@@ -29,6 +38,26 @@ func (m *MockProductService) FindByID(_ context.Context, id uint) (*response.Pro
 	return args.Get(0).(*response.ProductResponse), args.Error(1)
 }
 
+func (m *MockProductService) Create(_ context.Context, req *request.ProductRequest) (*response.ProductResponse, error) {
+	args := m.Called(req)
+	return args.Get(0).(*response.ProductResponse), args.Error(1)
+}
+
+func (m *MockProductService) Replace(_ context.Context, id uint, req *request.ProductRequest) (*response.ProductResponse, error) {
+	args := m.Called(id, req)
+	return args.Get(0).(*response.ProductResponse), args.Error(1)
+}
+
+func (m *MockProductService) Update(_ context.Context, id uint, req *request.ProductPatchRequest) (*response.ProductResponse, error) {
+	args := m.Called(id, req)
+	return args.Get(0).(*response.ProductResponse), args.Error(1)
+}
+
+func (m *MockProductService) Delete(_ context.Context, id uint) (*response.ProductResponse, error) {
+	args := m.Called(id)
+	return args.Get(0).(*response.ProductResponse), args.Error(1)
+}
+
 func TestListProducts(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -43,12 +72,12 @@ func TestListProducts(t *testing.T) {
 		},
 		{
 			name:           "error response",
-			mockErr:        errors.New("some error"),
+			mockErr:        errors2.New("some error"),
 			expectedStatus: http.StatusInternalServerError,
 		},
 		{
 			name:           "products not found",
-			mockErr:        errors2.ErrProductNotFound,
+			mockErr:        errors.ErrProductNotFound,
 			expectedStatus: http.StatusNotFound,
 		},
 	}
@@ -59,7 +88,7 @@ func TestListProducts(t *testing.T) {
 
 			mockService := new(MockProductService)
 			handler := NewProductHandler(mockService)
-			mockService.On("FindAll").Return(tt.mockRes, tt.mockErr)
+			mockService.On("FindAll", mock.Anything).Return(tt.mockRes, &response.Pagination{Limit: 50}, tt.mockErr)
 			handler.ListProducts(w, req)
 
 			resp := w.Result()
@@ -70,6 +99,121 @@ func TestListProducts(t *testing.T) {
 	}
 }
 
+func TestListProducts_Query(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		want           *request.ProductListRequest
+		expectedStatus int
+	}{
+		{
+			name:           "no parameters",
+			query:          "",
+			want:           &request.ProductListRequest{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "every parameter",
+			query: "?category=Waffle&minPrice=2.5&maxPrice=10&q=choc&sort=price&order=desc&limit=5&cursor=abc",
+			want: &request.ProductListRequest{
+				Category: "Waffle", Query: "choc", Sort: "price", Order: "desc", Limit: 5, Cursor: "abc",
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{name: "unknown sort", query: "?sort=id", expectedStatus: http.StatusBadRequest},
+		{name: "unknown order", query: "?sort=name&order=up", expectedStatus: http.StatusBadRequest},
+		{name: "invalid price", query: "?minPrice=cheap", expectedStatus: http.StatusBadRequest},
+		{name: "negative price", query: "?maxPrice=-1", expectedStatus: http.StatusBadRequest},
+		{name: "price range reversed", query: "?minPrice=10&maxPrice=2.5", expectedStatus: http.StatusBadRequest},
+		{name: "limit too large", query: "?limit=500", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=ten", expectedStatus: http.StatusBadRequest},
+		{name: "search too long", query: "?q=" + strings.Repeat("a", 101), expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/product"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockService := new(MockProductService)
+			var got *request.ProductListRequest
+			mockService.On("FindAll", mock.Anything).Run(func(args mock.Arguments) {
+				got = args.Get(0).(*request.ProductListRequest)
+			}).Return(&response.ProductsResponse{}, &response.Pagination{Limit: 50}, nil)
+			NewProductHandler(mockService).ListProducts(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.want == nil {
+				return
+			}
+			if got.Category != tt.want.Category || got.Query != tt.want.Query || got.Sort != tt.want.Sort ||
+				got.Order != tt.want.Order || got.Limit != tt.want.Limit || got.Cursor != tt.want.Cursor {
+				t.Errorf("expected request %+v, got %+v", tt.want, got)
+			}
+			if tt.query != "" && (got.MinPrice == nil || got.MinPrice.Decimal() != "2.50" || got.MaxPrice.Decimal() != "10.00") {
+				t.Errorf("unexpected price range %v - %v", got.MinPrice, got.MaxPrice)
+			}
+		})
+	}
+}
+
+func TestListProducts_Pagination(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/product?category=Waffle&limit=2&cursor=old", nil)
+	w := httptest.NewRecorder()
+
+	mockService := new(MockProductService)
+	mockService.On("FindAll", mock.Anything).Return(
+		&response.ProductsResponse{Products: []response.Product{{ID: "1"}, {ID: "2"}}},
+		&response.Pagination{Limit: 2, NextCursor: "next-page", HasMore: true}, nil)
+	NewProductHandler(mockService).ListProducts(w, req)
+
+	links := w.Header().Values("Link")
+	want := []string{
+		`</product?category=Waffle&limit=2>; rel="first"`,
+		`</product?category=Waffle&cursor=next-page&limit=2>; rel="next"`,
+	}
+	if len(links) != len(want) || links[0] != want[0] || links[1] != want[1] {
+		t.Errorf("expected links %v, got %v", want, links)
+	}
+
+	var body struct {
+		Pagination response.Pagination `json:"pagination"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !body.Pagination.HasMore || body.Pagination.NextCursor != "next-page" {
+		t.Errorf("expected pagination in the envelope, got %+v", body.Pagination)
+	}
+}
+
+func TestListProducts_InvalidCursor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/product?cursor=bad", nil)
+	w := httptest.NewRecorder()
+
+	mockService := new(MockProductService)
+	mockService.On("FindAll", mock.Anything).Return((*response.ProductsResponse)(nil), (*response.Pagination)(nil), errors.ErrInvalidCursor)
+	NewProductHandler(mockService).ListProducts(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestListProducts_InvalidCategory(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/product?category=%3F%3F%3F", nil)
+	w := httptest.NewRecorder()
+
+	mockService := new(MockProductService)
+	mockService.On("FindAll", mock.Anything).Return((*response.ProductsResponse)(nil), (*response.Pagination)(nil), errors.ErrInvalidCategoryName)
+	NewProductHandler(mockService).ListProducts(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
 func TestGetProductByID(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -92,13 +236,13 @@ func TestGetProductByID(t *testing.T) {
 		{
 			name:           "error finding product",
 			id:             uint(1),
-			mockErr:        errors.New("some error"),
+			mockErr:        errors2.New("some error"),
 			expectedStatus: http.StatusInternalServerError,
 		},
 		{
 			name:           "product not found",
 			id:             uint(1),
-			mockErr:        errors2.ErrProductNotFound,
+			mockErr:        errors.ErrProductNotFound,
 			expectedStatus: http.StatusNotFound,
 		},
 	}
@@ -123,3 +267,322 @@ func TestGetProductByID(t *testing.T) {
 		})
 	}
 }
+
+const validProductBody = `{"name":"Waffle","price":6.5,"category":"Waffle",
+"image":{"thumbnail":"t.jpg","mobile":"m.jpg","tablet":"t.jpg","desktop":"d.jpg"}}`
+
+func TestCreateProduct(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "successful request",
+			body:           validProductBody,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "malformed body",
+			body:           `{"name":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing image",
+			body:           `{"name":"Waffle","price":6.5,"category":"Waffle"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative price",
+			body:           validProductBody,
+			mockErr:        errors.ErrInvalidProductPrice,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "error creating product",
+			body:           validProductBody,
+			mockErr:        errors.ErrDatabaseError,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/product", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockProductService)
+			mockService.On("Create", mock.Anything).Return(&response.ProductResponse{ID: "1"}, tt.mockErr)
+			NewProductHandler(mockService).CreateProduct(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "successful request",
+			id:             "1",
+			body:           `{"price":"7.25"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid product id",
+			id:             "abc",
+			body:           `{"price":"7.25"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "empty name",
+			id:             "1",
+			body:           `{"name":""}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "product not found",
+			id:             "1",
+			body:           `{"category":"Cake"}`,
+			mockErr:        errors.ErrProductNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("productID", tt.id)
+			req := httptest.NewRequest(http.MethodPatch, "/product/"+tt.id, strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockProductService)
+			mockService.On("Update", uint(1), mock.Anything).Return(&response.ProductResponse{ID: "1"}, tt.mockErr)
+			NewProductHandler(mockService).UpdateProduct(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestReplaceProduct(t *testing.T) {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("productID", "3")
+	req := httptest.NewRequest(http.MethodPut, "/product/3", strings.NewReader(validProductBody))
+	req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+
+	mockService := new(MockProductService)
+	mockService.On("Replace", uint(3), mock.MatchedBy(func(r *request.ProductRequest) bool {
+		return r.Name == "Waffle" && r.Image.Desktop == "d.jpg" && r.Price.Decimal() == "6.50"
+	})).Return(&response.ProductResponse{ID: "3"}, nil)
+	NewProductHandler(mockService).ReplaceProduct(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteProduct(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockErr        error
+		expectedStatus int
+	}{
+		{name: "successful request", expectedStatus: http.StatusOK},
+		{name: "product not found", mockErr: errors.ErrProductNotFound, expectedStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("productID", "2")
+			req := httptest.NewRequest(http.MethodDelete, "/product/2", nil)
+			req = req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockProductService)
+			mockService.On("Delete", uint(2)).Return(&response.ProductResponse{ID: "2"}, tt.mockErr)
+			NewProductHandler(mockService).DeleteProduct(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestListCategoryProducts(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "Products of the category", expectedStatus: http.StatusOK},
+		{name: "Unknown category", err: errors.ErrCategoryNotFound, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("slug", "waffle")
+			req := httptest.NewRequest(http.MethodGet, "/category/waffle/product?sort=price", nil)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			mockService := new(MockProductService)
+			mockService.On("FindAll", mock.MatchedBy(func(r *request.ProductListRequest) bool {
+				return r.CategorySlug == "waffle" && r.Sort == "price"
+			})).Return(&response.ProductsResponse{Products: []response.Product{{ID: "1", Category: "Waffle"}}},
+				&response.Pagination{Limit: 50}, tt.err)
+			NewProductHandler(mockService).ListCategoryProducts(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		want           *request.ProductSearchRequest
+		expectedStatus int
+	}{
+		{name: "Prefix search", query: "q=choc&limit=5", want: &request.ProductSearchRequest{Query: "choc", Limit: 5},
+			expectedStatus: http.StatusOK},
+		{name: "Fuzzy search", query: "q=wafle&fuzzy=true", want: &request.ProductSearchRequest{Query: "wafle", Fuzzy: true},
+			expectedStatus: http.StatusOK},
+		{name: "Missing query", query: "", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid fuzzy", query: "q=choc&fuzzy=maybe", expectedStatus: http.StatusBadRequest},
+		{name: "Limit too large", query: "q=choc&limit=500", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/product/search?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockService := new(MockProductService)
+			if tt.want != nil {
+				mockService.On("Search", tt.want).Return(&response.ProductSearchResponse{Results: []response.ProductSearchResult{{
+					Product:   response.Product{ID: "1", Name: "Chocolate Waffle"},
+					Rank:      0.6,
+					Highlight: response.ProductHighlight{Name: "<mark>Chocolate</mark> Waffle", Category: "Waffle"},
+				}}}, nil)
+			}
+			NewProductHandler(mockService).SearchProducts(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.want != nil {
+				var body struct {
+					Data response.ProductSearchResponse `json:"data"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if len(body.Data.Results) != 1 || body.Data.Results[0].Highlight.Name != "<mark>Chocolate</mark> Waffle" {
+					t.Errorf("expected the highlighted result, got %+v", body.Data.Results)
+				}
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetProductByID_ConditionalGet(t *testing.T) {
+	updated := time.Date(2025, 3, 4, 10, 30, 15, 500, time.UTC)
+	product := &response.ProductResponse{ID: "1", Name: "Waffle", Category: "Waffle", UpdatedAt: updated}
+	get := func(header, value string) *httptest.ResponseRecorder {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("productID", "1")
+		req := httptest.NewRequest(http.MethodGet, "/product/1", nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+
+		mockService := new(MockProductService)
+		mockService.On("FindByID", uint(1)).Return(product, nil)
+		NewProductHandler(mockService).GetProductByID(w, req)
+		return w
+	}
+
+	first := get("", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", first.Code, etag)
+	}
+	if lm := first.Header().Get("Last-Modified"); lm != "Tue, 04 Mar 2025 10:30:15 GMT" {
+		t.Errorf("unexpected Last-Modified %q", lm)
+	}
+	if get("", "").Header().Get("ETag") != etag {
+		t.Error("expected the same ETag for the same product")
+	}
+
+	tests := []struct {
+		name           string
+		header, value  string
+		expectedStatus int
+	}{
+		{"Matching ETag", "If-None-Match", etag, http.StatusNotModified},
+		{"Weak ETag in a list", "If-None-Match", `"other", W/` + etag, http.StatusNotModified},
+		{"Any ETag", "If-None-Match", "*", http.StatusNotModified},
+		{"Changed ETag", "If-None-Match", `"other"`, http.StatusOK},
+		{"Not modified since", "If-Modified-Since", "Tue, 04 Mar 2025 10:30:15 GMT", http.StatusNotModified},
+		{"Modified since", "If-Modified-Since", "Tue, 04 Mar 2025 10:30:14 GMT", http.StatusOK},
+		{"Invalid date", "If-Modified-Since", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.header, tt.value)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != etag) {
+				t.Errorf("expected an empty 304 with the ETag, got %q %q", w.Body.String(), w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestListProducts_ConditionalGet(t *testing.T) {
+	list := func(etag string, products []response.Product) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/product", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+
+		mockService := new(MockProductService)
+		mockService.On("FindAll", mock.Anything).Return(&response.ProductsResponse{Products: products},
+			&response.Pagination{Limit: 50}, nil)
+		NewProductHandler(mockService).ListProducts(w, req)
+		return w
+	}
+
+	products := []response.Product{{ID: "1", Name: "Waffle"}, {ID: "2", Name: "Cake"}}
+	etag := list("", products).Header().Get("ETag")
+	if w := list(etag, products); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged list, got %d", w.Code)
+	}
+	if w := list(etag, products[:1]); w.Code != http.StatusOK {
+		t.Errorf("expected 200 once a product is gone, got %d", w.Code)
+	}
+}
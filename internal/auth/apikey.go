@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	errors2 "errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+)
+
+// maxCachedKeys bounds the cache, unknown keys are cached too so guessing keys does not
+// hit the database on every request.
+const maxCachedKeys = 10_000
+
+// KeyStore loads API keys by hash, repositories.APIKeyRepo implements it.
+type KeyStore interface {
+	FindByHash(ctx context.Context, hash string) (*db.APIKey, error)
+	TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// HashKey returns the hash API keys are stored and looked up by.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type cachedKey struct {
+	principal *Principal // nil for unknown keys
+	expires   time.Time
+}
+
+// APIKeyAuthenticator resolves API keys to principals. Lookups are cached for the TTL,
+// so a revoked key keeps working for at most the TTL unless it is invalidated.
+type APIKeyAuthenticator struct {
+	store KeyStore
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+func NewAPIKeyAuthenticator(store KeyStore, ttl time.Duration) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: make(map[string]cachedKey),
+	}
+}
+
+// Authenticate returns the principal of the API key. It fails with ErrInvalidAPIKey for
+// unknown or revoked keys and ErrAPIKeyExpired for expired ones.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, key string) (*Principal, error) {
+	hash := HashKey(key)
+	now := a.now()
+
+	a.mu.Lock()
+	entry, ok := a.cache[hash]
+	a.mu.Unlock()
+	if !ok || !now.Before(entry.expires) {
+		p, err := a.load(ctx, hash, now)
+		if err != nil {
+			return nil, err
+		}
+		entry = cachedKey{principal: p, expires: now.Add(a.ttl)}
+		a.remember(hash, entry, now)
+	}
+
+	if entry.principal == nil {
+		return nil, errors.ErrInvalidAPIKey
+	}
+	if entry.principal.expired(now) {
+		return nil, errors.ErrAPIKeyExpired
+	}
+
+	return entry.principal, nil
+}
+
+func (a *APIKeyAuthenticator) load(ctx context.Context, hash string, now time.Time) (*Principal, error) {
+	key, err := a.store.FindByHash(ctx, hash)
+	if errors2.Is(err, errors.ErrAPIKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// last use is recorded once per cache period, failing to record it does not fail the request
+	if err := a.store.TouchLastUsed(ctx, key.ID, now); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error recording use of api key %s: %v", key.ID, err)
+	}
+
+	return &Principal{
+		ID:        key.ID.String(),
+		Name:      key.Name,
+		Scopes:    key.ScopeList(),
+		ExpiresAt: key.ExpiresAt,
+	}, nil
+}
+
+func (a *APIKeyAuthenticator) remember(hash string, entry cachedKey, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= maxCachedKeys {
+		for h, e := range a.cache {
+			if !now.Before(e.expires) {
+				delete(a.cache, h)
+			}
+		}
+		if len(a.cache) >= maxCachedKeys {
+			clear(a.cache)
+		}
+	}
+
+	a.cache[hash] = entry
+}
+
+// Invalidate drops the cached lookup of the key with the given hash, e.g. after it was
+// revoked.
+func (a *APIKeyAuthenticator) Invalidate(hash string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cache, hash)
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	errors2 "errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+)
+
+// memoryKeyStore implements KeyStore for testing
+type memoryKeyStore struct {
+	keys    map[string]*db.APIKey
+	lookups int
+	touched int
+}
+
+func (m *memoryKeyStore) FindByHash(_ context.Context, hash string) (*db.APIKey, error) {
+	m.lookups++
+	if k, ok := m.keys[hash]; ok {
+		return k, nil
+	}
+
+	return nil, errors.ErrAPIKeyNotFound
+}
+
+func (m *memoryKeyStore) TouchLastUsed(_ context.Context, _ uuid.UUID, _ time.Time) error {
+	m.touched++
+	return nil
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Minute)
+	store := &memoryKeyStore{keys: map[string]*db.APIKey{
+		HashKey("valid"):   {ID: uuid.New(), Name: "valid", Scopes: "read_orders create_order"},
+		HashKey("expired"): {ID: uuid.New(), Name: "expired", Scopes: "read_orders", ExpiresAt: &expired},
+	}}
+	a := NewAPIKeyAuthenticator(store, 30*time.Second)
+	a.now = func() time.Time { return now }
+
+	p, err := a.Authenticate(t.Context(), "valid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Name != "valid" || !p.HasScope(ScopeCreateOrder) || p.HasScope(ScopeAdmin) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	if _, err := a.Authenticate(t.Context(), "valid"); err != nil || store.lookups != 1 || store.touched != 1 {
+		t.Errorf("expected cached lookup, got %d lookups, %d touches, err %v", store.lookups, store.touched, err)
+	}
+
+	if _, err := a.Authenticate(t.Context(), "unknown"); !errors2.Is(err, errors.ErrInvalidAPIKey) {
+		t.Errorf("expected invalid api key, got %v", err)
+	}
+	if _, err := a.Authenticate(t.Context(), "unknown"); !errors2.Is(err, errors.ErrInvalidAPIKey) || store.lookups != 2 {
+		t.Errorf("expected unknown key to be cached, got %d lookups, err %v", store.lookups, err)
+	}
+
+	if _, err := a.Authenticate(t.Context(), "expired"); !errors2.Is(err, errors.ErrAPIKeyExpired) {
+		t.Errorf("expected expired api key, got %v", err)
+	}
+
+	// revoked keys disappear from the store and stop working once the cache expires
+	delete(store.keys, HashKey("valid"))
+	if _, err := a.Authenticate(t.Context(), "valid"); err != nil {
+		t.Errorf("expected cached key to work until the TTL, got %v", err)
+	}
+	now = now.Add(31 * time.Second)
+	if _, err := a.Authenticate(t.Context(), "valid"); !errors2.Is(err, errors.ErrInvalidAPIKey) {
+		t.Errorf("expected revoked key to be rejected after the TTL, got %v", err)
+	}
+}
+
+func TestAPIKeyAuthenticator_Invalidate(t *testing.T) {
+	store := &memoryKeyStore{keys: map[string]*db.APIKey{
+		HashKey("key"): {ID: uuid.New(), Scopes: "admin"},
+	}}
+	a := NewAPIKeyAuthenticator(store, time.Hour)
+
+	if _, err := a.Authenticate(t.Context(), "key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	delete(store.keys, HashKey("key"))
+	a.Invalidate(HashKey("key"))
+	if _, err := a.Authenticate(t.Context(), "key"); !errors2.Is(err, errors.ErrInvalidAPIKey) {
+		t.Errorf("expected invalidated key to be rejected, got %v", err)
+	}
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	admin := &Principal{Scopes: []string{ScopeAdmin}}
+	if !admin.HasScope(ScopeUpdateOrders) {
+		t.Error("expected admin to have every scope")
+	}
+
+	reader := &Principal{Scopes: []string{ScopeReadOrders}}
+	if reader.HasScope(ScopeUpdateOrders) {
+		t.Error("expected reader not to have update_orders")
+	}
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	errors2 "errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+)
+
+const (
+	defaultScopeClaim      = "scope"
+	defaultCustomerIDClaim = "sub"
+	minHMACSecretLength    = 32
+	maxCustomerIDLength    = 64 // client and customer IDs are stored in VARCHAR(64) columns
+)
+
+// JWTAuthenticator resolves bearer tokens to principals. Tokens must be signed with the
+// configured algorithm, carry an expiry and match the issuer and audience when set.
+type JWTAuthenticator struct {
+	parser          *jwt.Parser
+	keyFunc         jwt.Keyfunc
+	scopeClaim      string
+	customerIDClaim string
+}
+
+func NewJWTAuthenticator(cfg config.JWTConfig) (*JWTAuthenticator, error) {
+	keyFunc, err := loadKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	a := &JWTAuthenticator{
+		parser:          jwt.NewParser(opts...),
+		keyFunc:         keyFunc,
+		scopeClaim:      cfg.ScopeClaim,
+		customerIDClaim: cfg.CustomerIDClaim,
+	}
+	if a.scopeClaim == "" {
+		a.scopeClaim = defaultScopeClaim
+	}
+	if a.customerIDClaim == "" {
+		a.customerIDClaim = defaultCustomerIDClaim
+	}
+
+	return a, nil
+}
+
+// Authenticate verifies the token and returns its principal. The subject identifies the
+// principal, the customer ID and scope claims give its customer ID and scopes.
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.keyFunc); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid bearer token: %v", err)
+		if errors2.Is(err, jwt.ErrTokenExpired) {
+			return nil, errors.ErrTokenExpired
+		}
+		return nil, errors.ErrInvalidToken
+	}
+
+	customerID, _ := claims[a.customerIDClaim].(string)
+	if customerID == "" || len(customerID) > maxCustomerIDLength {
+		log.WithCtx(ctx).Error().Msgf("Bearer token has no usable %s claim", a.customerIDClaim)
+		return nil, errors.ErrInvalidToken
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" || len(subject) > maxCustomerIDLength {
+		subject = customerID
+	}
+
+	p := &Principal{
+		ID:         subject,
+		Name:       subject,
+		CustomerID: customerID,
+		Scopes:     scopesClaim(claims[a.scopeClaim]),
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt := exp.Time
+		p.ExpiresAt = &expiresAt
+	}
+
+	return p, nil
+}
+
+// scopesClaim reads a space separated scope string (RFC 8693) or an array of scopes.
+func scopesClaim(v any) []string {
+	switch scopes := v.(type) {
+	case string:
+		return strings.Fields(scopes)
+	case []any:
+		res := make([]string, 0, len(scopes))
+		for _, s := range scopes {
+			if str, ok := s.(string); ok {
+				res = append(res, str)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+func loadKeys(cfg config.JWTConfig) (jwt.Keyfunc, error) {
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret, err := os.ReadFile(cfg.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt secret: %w", err)
+		}
+		secret = []byte(strings.TrimSpace(string(secret)))
+		if len(secret) < minHMACSecretLength {
+			return nil, fmt.Errorf("jwt secret must be at least %d bytes", minHMACSecretLength)
+		}
+
+		return func(*jwt.Token) (any, error) { return secret, nil }, nil
+	case jwt.SigningMethodRS256.Alg():
+		if cfg.JWKSFile != "" {
+			keys, err := readJWKSFile(cfg.JWKSFile)
+			if err != nil {
+				return nil, err
+			}
+
+			return jwksKeyFunc(keys), nil
+		}
+
+		pem, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwt public key: %w", err)
+		}
+
+		return func(*jwt.Token) (any, error) { return key, nil }, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", cfg.Algorithm)
+	}
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// readJWKSFile loads the RSA signing keys of a JSON Web Key Set, other keys are skipped.
+func readJWKSFile(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of jwk %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid exponent of jwk %q", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks %s has no RSA signing keys", path)
+	}
+
+	return keys, nil
+}
+
+// jwksKeyFunc picks the key by the kid header, tokens without kid are accepted when the
+// set has a single key.
+func jwksKeyFunc(keys map[string]*rsa.PublicKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	errors2 "errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return s
+}
+
+func claims(exp time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "cust-42",
+		"iss":   "https://auth.example.com",
+		"aud":   "kart",
+		"exp":   exp.Unix(),
+		"scope": "read_products create_order",
+	}
+}
+
+func TestJWTAuthenticator_HS256(t *testing.T) {
+	a, err := NewJWTAuthenticator(config.JWTConfig{
+		Algorithm:  "HS256",
+		SecretFile: writeFile(t, "secret", []byte(testSecret+"\n")),
+		Issuer:     "https://auth.example.com",
+		Audience:   "kart",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key := []byte(testSecret)
+	future := time.Now().Add(time.Hour)
+
+	p, err := a.Authenticate(t.Context(), sign(t, jwt.SigningMethodHS256, key, "", claims(future)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.ID != "cust-42" || p.CustomerID != "cust-42" || !p.HasScope(ScopeCreateOrder) || p.HasScope(ScopeReadOrders) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+	if p.ExpiresAt == nil || p.ExpiresAt.Unix() != future.Unix() {
+		t.Errorf("expected expiry %s, got %v", future, p.ExpiresAt)
+	}
+
+	expired := sign(t, jwt.SigningMethodHS256, key, "", claims(time.Now().Add(-time.Minute)))
+	if _, err := a.Authenticate(t.Context(), expired); !errors2.Is(err, errors.ErrTokenExpired) {
+		t.Errorf("expected expired token, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(c jwt.MapClaims)
+		key    []byte
+	}{
+		{name: "wrong secret", key: []byte("another secret of at least 32 bytes")},
+		{name: "wrong issuer", mutate: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "wrong audience", mutate: func(c jwt.MapClaims) { c["aud"] = "other" }},
+		{name: "no expiry", mutate: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "no subject", mutate: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := claims(future)
+			if tt.mutate != nil {
+				tt.mutate(c)
+			}
+			signingKey := key
+			if tt.key != nil {
+				signingKey = tt.key
+			}
+			if _, err := a.Authenticate(t.Context(), sign(t, jwt.SigningMethodHS256, signingKey, "", c)); !errors2.Is(err, errors.ErrInvalidToken) {
+				t.Errorf("expected invalid token, got %v", err)
+			}
+		})
+	}
+
+	if _, err := a.Authenticate(t.Context(), "not.a.token"); !errors2.Is(err, errors.ErrInvalidToken) {
+		t.Errorf("expected invalid token, got %v", err)
+	}
+}
+
+func TestJWTAuthenticator_RS256(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	}}})
+
+	configs := map[string]config.JWTConfig{
+		"pem": {
+			Algorithm:     "RS256",
+			PublicKeyFile: writeFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		},
+		"jwks": {
+			Algorithm:       "RS256",
+			JWKSFile:        writeFile(t, "jwks.json", jwks),
+			ScopeClaim:      "scp",
+			CustomerIDClaim: "customer_id",
+		},
+	}
+	for name, cfg := range configs {
+		t.Run(name, func(t *testing.T) {
+			a, err := NewJWTAuthenticator(cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			c := claims(time.Now().Add(time.Hour))
+			c["scp"] = []any{"read_orders"}
+			c["customer_id"] = "cust-7"
+			p, err := a.Authenticate(t.Context(), sign(t, jwt.SigningMethodRS256, rsaKey, "k1", c))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name == "jwks" && (p.ID != "cust-42" || p.CustomerID != "cust-7" || !p.HasScope(ScopeReadOrders)) {
+				t.Errorf("unexpected principal %+v", p)
+			}
+			if name == "pem" && (p.CustomerID != "cust-42" || !p.HasScope(ScopeCreateOrder)) {
+				t.Errorf("unexpected principal %+v", p)
+			}
+
+			// a HS256 token signed with the public key must not pass as RS256
+			forged := sign(t, jwt.SigningMethodHS256, der, "k1", c)
+			if _, err := a.Authenticate(t.Context(), forged); !errors2.Is(err, errors.ErrInvalidToken) {
+				t.Errorf("expected invalid token, got %v", err)
+			}
+		})
+	}
+
+	a, err := NewJWTAuthenticator(configs["jwks"])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := claims(time.Now().Add(time.Hour))
+	c["customer_id"] = "cust-7"
+	if _, err := a.Authenticate(t.Context(), sign(t, jwt.SigningMethodRS256, rsaKey, "k2", c)); !errors2.Is(err, errors.ErrInvalidToken) {
+		t.Errorf("expected unknown kid to be rejected, got %v", err)
+	}
+}
+
+func TestNewJWTAuthenticator_Errors(t *testing.T) {
+	tests := map[string]config.JWTConfig{
+		"short secret":   {Algorithm: "HS256", SecretFile: writeFile(t, "short", []byte("secret"))},
+		"missing secret": {Algorithm: "HS256", SecretFile: filepath.Join(t.TempDir(), "missing")},
+		"invalid pem":    {Algorithm: "RS256", PublicKeyFile: writeFile(t, "key.pem", []byte("not a key"))},
+		"empty jwks":     {Algorithm: "RS256", JWKSFile: writeFile(t, "jwks.json", []byte(`{"keys":[]}`))},
+		"unsupported":    {Algorithm: "none"},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewJWTAuthenticator(cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestScopesClaim(t *testing.T) {
+	if s := scopesClaim("read_orders  create_order"); len(s) != 2 || s[1] != "create_order" {
+		t.Errorf("unexpected scopes %v", s)
+	}
+	if s := scopesClaim([]any{"admin", 1}); len(s) != 1 || s[0] != "admin" {
+		t.Errorf("unexpected scopes %v", s)
+	}
+	if s := scopesClaim(nil); s != nil {
+		t.Errorf("expected no scopes, got %v", s)
+	}
+}
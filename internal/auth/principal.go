@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/constants"
+)
+
+// Scopes API keys and bearer tokens can be granted. ScopeAdmin grants every scope.
+const (
+	ScopeReadProducts = "read_products"
+	ScopeCreateOrder  = "create_order"
+	ScopeReadOrders   = "read_orders"
+	ScopeUpdateOrders = "update_orders"
+	ScopeAdmin        = "admin"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID         string // API key ID or token subject, used as client ID for idempotency keys
+	Name       string
+	CustomerID string // customer of a bearer token, empty for API keys
+	Scopes     []string
+	ExpiresAt  *time.Time
+}
+
+// HasScope tells whether the principal was granted the scope, directly or through admin.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+func (p *Principal) expired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+// WithPrincipal stores the principal in the context, and in the slot added by
+// WithPrincipalSlot further up the chain.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	if slot, ok := ctx.Value(constants.PrincipalSlotKey).(**Principal); ok {
+		*slot = p
+	}
+
+	return context.WithValue(ctx, constants.PrincipalKey, p)
+}
+
+// WithPrincipalSlot adds an empty slot WithPrincipal fills, so middleware that runs before
+// authentication can read the principal from its own context once the request is served.
+func WithPrincipalSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, constants.PrincipalSlotKey, new(*Principal))
+}
+
+// FromContext returns the principal of the request or nil when it is not authenticated.
+func FromContext(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(constants.PrincipalKey).(*Principal); ok {
+		return p
+	}
+	if slot, ok := ctx.Value(constants.PrincipalSlotKey).(**Principal); ok {
+		return *slot
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size bounded cache whose entries expire after a TTL. When it is full the least
+// recently used entry is evicted. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu         sync.Mutex
+	order      *list.List // front is the most recently used entry
+	entries    map[K]*list.Element
+	generation uint64 // bumped by Purge
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func NewLRU[K comparable, V any](size int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value cached for key, expired entries are dropped and not returned.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set caches value for key for the TTL, evicting the least recently used entry when the
+// cache is full.
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value)
+}
+
+func (c *LRU[K, V]) set(key K, value V) {
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// SetIfGeneration caches value for key like Set, unless the cache was purged since gen was
+// read from Generation. A value loaded before a purge may be older than the write that
+// purged the cache, storing it would keep the old value for the TTL.
+func (c *LRU[K, V]) SetIfGeneration(key K, value V, gen uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != gen {
+		return false
+	}
+
+	c.set(key, value)
+	return true
+}
+
+// Generation counts the purges of the cache.
+func (c *LRU[K, V]) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Purge drops every entry.
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+	c.generation++
+}
+
+// Len is the number of cached entries, expired ones included until they are looked up or
+// evicted.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry[K, V]).key)
+}
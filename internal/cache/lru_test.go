@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, ok := c.Get("a"); !ok { // a is now more recent than b
+		t.Fatal("expected a to be cached")
+	}
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("expected a to stay cached, got %d %v", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("expected c to be cached, got %d %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
+
+func TestLRU_Expires(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewLRU[string, int](10, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached until the TTL")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to expire after the TTL")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected the expired entry to be dropped, got %d entries", c.Len())
+	}
+
+	// setting a key again renews it
+	c.Set("b", 1)
+	now = now.Add(30 * time.Second)
+	c.Set("b", 2)
+	now = now.Add(45 * time.Second)
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Errorf("expected the renewed value, got %d %v", v, ok)
+	}
+}
+
+func TestLRU_Purge(t *testing.T) {
+	c := NewLRU[string, int](10, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Purge()
+
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Errorf("expected an empty cache after purge, got %d entries", c.Len())
+	}
+	c.Set("a", 3)
+	if v, ok := c.Get("a"); !ok || v != 3 {
+		t.Errorf("expected the cache to work after purge, got %d %v", v, ok)
+	}
+}
+
+func TestLRU_SetIfGeneration(t *testing.T) {
+	c := NewLRU[string, int](10, time.Minute)
+	gen := c.Generation()
+	if !c.SetIfGeneration("a", 1, gen) {
+		t.Error("expected the value to be stored without a purge in between")
+	}
+
+	c.Purge()
+	if c.SetIfGeneration("a", 2, gen) {
+		t.Error("expected a value read before the purge to be dropped")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected no cached value after the purge")
+	}
+	if !c.SetIfGeneration("a", 3, c.Generation()) {
+		t.Error("expected the value to be stored with the current generation")
+	}
+}
@@ -1,23 +1,57 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	validate "github.com/go-playground/validator/v10"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server     ServerConfig     `yaml:"server"`
-	Database   DatabaseConfig   `yaml:"database"`
-	Logging    LoggingConfig    `yaml:"logging"`
-	CouponCode CouponCodeConfig `yaml:"couponCode"`
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Logging      LoggingConfig      `yaml:"logging"`
+	CouponCode   CouponCodeConfig   `yaml:"couponCode"`
+	Idempotency  IdempotencyConfig  `yaml:"idempotency"`
+	Auth         AuthConfig         `yaml:"auth"`
+	RateLimit    RateLimitConfig    `yaml:"rateLimit"`
+	Health       HealthConfig       `yaml:"health"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	Tracing      TracingConfig      `yaml:"tracing"`
+	ProductCache ProductCacheConfig `yaml:"productCache"`
 }
 
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port" validate:"required"`
+	Host              string        `yaml:"host"`
+	Port              int           `yaml:"port" validate:"required"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`       // whole request including the body, 15s by default
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"` // 5s by default
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`      // 30s by default
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`       // keep-alive connections, 60s by default
+	DrainPeriod       time.Duration `yaml:"drainPeriod"`       // readiness fails this long before shutdown starts, 5s by default
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"`   // in-flight requests get this long to finish, 20s by default
+}
+
+func (c *ServerConfig) setDefaults() {
+	defaults := []struct {
+		value *time.Duration
+		def   time.Duration
+	}{
+		{&c.ReadTimeout, 15 * time.Second},
+		{&c.ReadHeaderTimeout, 5 * time.Second},
+		{&c.WriteTimeout, 30 * time.Second},
+		{&c.IdleTimeout, 60 * time.Second},
+		{&c.DrainPeriod, 5 * time.Second},
+		{&c.ShutdownTimeout, 20 * time.Second},
+	}
+	for _, d := range defaults {
+		if *d.value <= 0 {
+			*d.value = d.def
+		}
+	}
 }
 
 type DatabaseConfig struct {
@@ -34,11 +68,121 @@ type DatabaseConfig struct {
 type CouponCodeConfig struct {
 	Unzipped  bool     `yaml:"unzipped"`
 	FilePaths []string `yaml:"filePaths"`
+	IndexPath string   `yaml:"indexPath"` // prebuilt index file, rebuilt when the source files change
+	Validator string   `yaml:"validator" validate:"omitempty,oneof=file index database hybrid"`
+	Chain     []string `yaml:"chain" validate:"dive,oneof=file index database"` // validators tried in hybrid mode
+}
+
+type IdempotencyConfig struct {
+	TTL           time.Duration `yaml:"ttl"`           // how long a stored response is replayed, e.g. "24h"
+	PurgeInterval time.Duration `yaml:"purgeInterval"` // how often expired keys are deleted, 1h by default
+}
+
+type AuthConfig struct {
+	Mode     string        `yaml:"mode" validate:"omitempty,oneof=apikey jwt both"` // credentials accepted, apikey by default
+	CacheTTL time.Duration `yaml:"cacheTTL"`                                        // how long API key lookups are cached, e.g. "30s"
+	JWT      JWTConfig     `yaml:"jwt"`
+}
+
+// JWTConfig configures bearer token validation. HS256 tokens are checked with the secret
+// file, RS256 tokens with the PEM public key or the keys of a JWKS file.
+type JWTConfig struct {
+	Algorithm       string        `yaml:"algorithm" validate:"omitempty,oneof=HS256 RS256"`
+	SecretFile      string        `yaml:"secretFile" validate:"required_if=Algorithm HS256"`
+	PublicKeyFile   string        `yaml:"publicKeyFile" validate:"required_if=Algorithm RS256 JWKSFile ''"`
+	JWKSFile        string        `yaml:"jwksFile"`
+	Issuer          string        `yaml:"issuer"`
+	Audience        string        `yaml:"audience"`
+	Leeway          time.Duration `yaml:"leeway"`          // clock skew allowed for exp and nbf, e.g. "30s"
+	ScopeClaim      string        `yaml:"scopeClaim"`      // claim holding the scopes, "scope" by default
+	CustomerIDClaim string        `yaml:"customerIdClaim"` // claim holding the customer ID, "sub" by default
+}
+
+// Auth modes
+const (
+	AuthModeAPIKey = "apikey"
+	AuthModeJWT    = "jwt"
+	AuthModeBoth   = "both"
+)
+
+// APIKeysEnabled tells whether requests may authenticate with the api_key header.
+func (c AuthConfig) APIKeysEnabled() bool {
+	return c.Mode != AuthModeJWT
+}
+
+// JWTEnabled tells whether requests may authenticate with a bearer token.
+func (c AuthConfig) JWTEnabled() bool {
+	return c.Mode == AuthModeJWT || c.Mode == AuthModeBoth
+}
+
+// RateLimitConfig limits requests per client, identified by API key or token subject.
+// Routes are keyed by method and chi pattern, e.g. "POST /order", the default policy
+// applies to the other routes. The IP policy is checked per IP before authentication,
+// so requests with missing or wrong credentials are limited too.
+type RateLimitConfig struct {
+	Enabled bool                       `yaml:"enabled"`
+	Store   string                     `yaml:"store" validate:"omitempty,oneof=memory"` // where buckets are kept, memory by default
+	IP      RateLimitPolicy            `yaml:"ip"`
+	Default RateLimitPolicy            `yaml:"default"`
+	Routes  map[string]RateLimitPolicy `yaml:"routes" validate:"dive"`
+}
+
+// RateLimitPolicy is a token bucket refilled with limit tokens per period.
+type RateLimitPolicy struct {
+	Limit  int           `yaml:"limit" validate:"gte=0"` // requests per period, 0 for no limit
+	Period time.Duration `yaml:"period" validate:"required_with=Limit"`
+	Burst  int           `yaml:"burst" validate:"gte=0"` // bucket size, limit when not set
+}
+
+// Rate limit stores
+const RateLimitStoreMemory = "memory"
+
+type HealthConfig struct {
+	CacheTTL      time.Duration `yaml:"cacheTTL"`      // how long check results are reused, 5s by default
+	CheckTimeout  time.Duration `yaml:"checkTimeout"`  // per check, 2s by default
+	MinFreeDiskMB int           `yaml:"minFreeDiskMB"` // free space needed next to the coupon files, 100 by default
+}
+
+// MetricsConfig serves the Prometheus metrics on their own port, which is not exposed
+// outside the cluster, so they are not reachable through the public API.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    int    `yaml:"port" validate:"omitempty,gt=0,lt=65536"` // 9090 by default
+	Path    string `yaml:"path" validate:"omitempty,startswith=/"`  // where Prometheus scrapes, /metrics by default
+}
+
+// TracingConfig configures OpenTelemetry tracing. Spans are sent to an OTLP/HTTP
+// collector or written as JSON to stdout, or to a file when FilePath is set.
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter" validate:"omitempty,oneof=otlp stdout"` // otlp by default
+	Endpoint    string  `yaml:"endpoint"`                                        // collector host:port, localhost:4318 by default
+	Insecure    bool    `yaml:"insecure"`                                        // plain HTTP to the collector
+	FilePath    string  `yaml:"filePath"`                                        // stdout exporter only
+	SampleRatio float64 `yaml:"sampleRatio" validate:"gte=0,lte=1"`              // share of new traces recorded, 1 when not set
+}
+
+// ProductCacheConfig configures the in-memory cache of catalog reads. Every instance has its
+// own cache and a write only clears the cache of the instance that served it, so the other
+// instances can serve the old catalog for up to the TTL after a write.
+type ProductCacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Size    int           `yaml:"size" validate:"gte=0"` // cached reads, 1000 by default
+	TTL     time.Duration `yaml:"ttl"`                   // how stale other instances can be, 1m by default
 }
 
 type LoggingConfig struct {
-	Level      string `json:"level"`
-	JsonFormat bool   `yaml:"jsonFormat"`
+	Level      string          `json:"level"`
+	JsonFormat bool            `yaml:"jsonFormat"`
+	Access     AccessLogConfig `yaml:"access"`
+}
+
+// AccessLogConfig configures the per request log. Client and server errors are always
+// logged, successful requests can be sampled.
+type AccessLogConfig struct {
+	SampleRate float64 `yaml:"sampleRate" validate:"gte=0,lte=1"` // share of 2xx requests logged, 1 when not set
+	CommonLog  bool    `yaml:"commonLog"`                         // also write every request in the Common Log Format
+	CommonFile string  `yaml:"commonFile"`                        // where Common Log Format lines go, stdout by default
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -55,6 +199,73 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	cfg.Server.setDefaults()
+
+	if cfg.Idempotency.TTL <= 0 {
+		cfg.Idempotency.TTL = 24 * time.Hour
+	}
+
+	if cfg.Idempotency.PurgeInterval <= 0 {
+		cfg.Idempotency.PurgeInterval = time.Hour
+	}
+
+	if cfg.Auth.Mode == "" {
+		cfg.Auth.Mode = AuthModeAPIKey
+	}
+
+	if cfg.Auth.CacheTTL <= 0 {
+		cfg.Auth.CacheTTL = 30 * time.Second
+	}
+
+	if cfg.Health.CacheTTL <= 0 {
+		cfg.Health.CacheTTL = 5 * time.Second
+	}
+
+	if cfg.Health.CheckTimeout <= 0 {
+		cfg.Health.CheckTimeout = 2 * time.Second
+	}
+
+	if cfg.Health.MinFreeDiskMB <= 0 {
+		cfg.Health.MinFreeDiskMB = 100
+	}
+
+	if cfg.Metrics.Port == 0 {
+		cfg.Metrics.Port = 9090
+	}
+
+	if cfg.Metrics.Path == "" {
+		cfg.Metrics.Path = "/metrics"
+	}
+
+	if cfg.Logging.Access.SampleRate <= 0 {
+		cfg.Logging.Access.SampleRate = 1
+	}
+
+	if cfg.Tracing.Exporter == "" {
+		cfg.Tracing.Exporter = "otlp"
+	}
+
+	if cfg.Tracing.SampleRatio <= 0 {
+		cfg.Tracing.SampleRatio = 1
+	}
+
+	if cfg.ProductCache.Size <= 0 {
+		cfg.ProductCache.Size = 1000
+	}
+
+	if cfg.ProductCache.TTL <= 0 {
+		cfg.ProductCache.TTL = time.Minute
+	}
+
+	if cfg.RateLimit.Store == "" {
+		cfg.RateLimit.Store = RateLimitStoreMemory
+	}
+
+	if cfg.Auth.JWTEnabled() && cfg.Auth.JWT.Algorithm == "" {
+		log.Printf("config validation failed: auth mode %s needs a jwt algorithm", cfg.Auth.Mode)
+		return nil, fmt.Errorf("auth mode %s needs a jwt algorithm", cfg.Auth.Mode)
+	}
+
 	if err := validate.New().Struct(cfg); err != nil {
 		log.Printf("config validation failed: %v", err)
 		return nil, err
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_ValidFile(t *testing.T) {
@@ -22,6 +24,8 @@ database:
 logging:
   level: debug
   jsonFormat: true
+idempotency:
+  ttl: 1h30m
 couponCode:
   unzipped: false
   filePaths:
@@ -55,6 +59,18 @@ couponCode:
 	if !cfg.Logging.JsonFormat {
 		t.Errorf("expected logging jsonFormat true, got false")
 	}
+	if cfg.Idempotency.TTL != 90*time.Minute {
+		t.Errorf("expected idempotency ttl 1h30m, got %s", cfg.Idempotency.TTL)
+	}
+	if cfg.Idempotency.PurgeInterval != time.Hour {
+		t.Errorf("expected default idempotency purge interval 1h, got %s", cfg.Idempotency.PurgeInterval)
+	}
+	if cfg.Auth.Mode != AuthModeAPIKey || cfg.Auth.JWTEnabled() {
+		t.Errorf("expected default auth mode apikey, got %s", cfg.Auth.Mode)
+	}
+	if cfg.Auth.CacheTTL != 30*time.Second {
+		t.Errorf("expected default auth cache ttl 30s, got %s", cfg.Auth.CacheTTL)
+	}
 	if cfg.CouponCode.Unzipped != false {
 		t.Errorf("expected unzipped false, got true")
 	}
@@ -79,3 +95,194 @@ func TestLoadConfig_InvalidFile(t *testing.T) {
 		t.Errorf("LoadConfig(%q) expected to fail, but succeeded with config: %+v", invalidPath, cfg)
 	}
 }
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+const baseConfig = `
+server:
+  port: 8080
+database:
+  host: "dbhost"
+  port: 5432
+  name: "testdb"
+  user: "testuser"
+  password: "testpass"
+  type: "postgres"
+`
+
+func TestLoadConfig_JWT(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    string
+		wantErr bool
+	}{
+		{
+			name: "hs256",
+			auth: "auth:\n  mode: both\n  jwt:\n    algorithm: HS256\n    secretFile: secret\n    leeway: 30s\n",
+		},
+		{
+			name: "rs256 with jwks",
+			auth: "auth:\n  mode: jwt\n  jwt:\n    algorithm: RS256\n    jwksFile: jwks.json\n",
+		},
+		{
+			name:    "missing algorithm",
+			auth:    "auth:\n  mode: jwt\n",
+			wantErr: true,
+		},
+		{
+			name:    "hs256 without secret",
+			auth:    "auth:\n  mode: jwt\n  jwt:\n    algorithm: HS256\n",
+			wantErr: true,
+		},
+		{
+			name:    "rs256 without key",
+			auth:    "auth:\n  mode: jwt\n  jwt:\n    algorithm: RS256\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			auth:    "auth:\n  mode: oauth\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfig(t, baseConfig+tt.auth))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got config %+v", cfg.Auth)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !cfg.Auth.JWTEnabled() {
+				t.Errorf("expected jwt to be enabled for mode %s", cfg.Auth.Mode)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_RateLimit(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, baseConfig+`
+rateLimit:
+  enabled: true
+  ip:
+    limit: 300
+    period: 1m
+  default:
+    limit: 100
+    period: 1m
+  routes:
+    "POST /order":
+      limit: 5
+      period: 1m
+      burst: 2
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RateLimit.Store != RateLimitStoreMemory {
+		t.Errorf("expected default store memory, got %s", cfg.RateLimit.Store)
+	}
+	if p := cfg.RateLimit.Routes["POST /order"]; p.Limit != 5 || p.Period != time.Minute || p.Burst != 2 {
+		t.Errorf("unexpected order policy %+v", p)
+	}
+	if p := cfg.RateLimit.IP; p.Limit != 300 || p.Period != time.Minute {
+		t.Errorf("unexpected ip policy %+v", p)
+	}
+
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"rateLimit:\n  default:\n    limit: 5\n")); err == nil {
+		t.Error("expected a limit without period to be rejected")
+	}
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"rateLimit:\n  ip:\n    limit: 5\n")); err == nil {
+		t.Error("expected an ip limit without period to be rejected")
+	}
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"rateLimit:\n  store: redis\n")); err == nil {
+		t.Error("expected an unknown store to be rejected")
+	}
+}
+
+func TestLoadConfig_ServerDefaults(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, strings.Replace(baseConfig, "port: 8080", "port: 8080\n  shutdownTimeout: 45s", 1)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Server.ShutdownTimeout != 45*time.Second {
+		t.Errorf("expected shutdown timeout 45s, got %s", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.Server.ReadHeaderTimeout != 5*time.Second || cfg.Server.WriteTimeout != 30*time.Second || cfg.Server.DrainPeriod != 5*time.Second {
+		t.Errorf("unexpected server defaults %+v", cfg.Server)
+	}
+}
+
+func TestLoadConfig_Metrics(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, baseConfig+"metrics:\n  enabled: true\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Metrics.Port != 9090 || cfg.Metrics.Path != "/metrics" {
+		t.Errorf("unexpected metrics defaults %+v", cfg.Metrics)
+	}
+
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"metrics:\n  port: 70000\n")); err == nil {
+		t.Error("expected an invalid metrics port to be rejected")
+	}
+}
+
+func TestLoadConfig_Tracing(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, baseConfig+"tracing:\n  enabled: true\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Tracing.Exporter != "otlp" || cfg.Tracing.SampleRatio != 1 {
+		t.Errorf("unexpected tracing defaults %+v", cfg.Tracing)
+	}
+
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"tracing:\n  exporter: jaeger\n")); err == nil {
+		t.Error("expected an unknown exporter to be rejected")
+	}
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"tracing:\n  sampleRatio: 1.5\n")); err == nil {
+		t.Error("expected a sample ratio above 1 to be rejected")
+	}
+}
+
+func TestLoadConfig_AccessLog(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, baseConfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Logging.Access.SampleRate != 1 || cfg.Logging.Access.CommonLog {
+		t.Errorf("unexpected access log defaults %+v", cfg.Logging.Access)
+	}
+
+	if _, err := LoadConfig(writeConfig(t, baseConfig+"logging:\n  access:\n    sampleRate: 2\n")); err == nil {
+		t.Error("expected a sample rate above 1 to be rejected")
+	}
+}
+
+func TestLoadConfig_ProductCache(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, baseConfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProductCache.Enabled || cfg.ProductCache.Size != 1000 || cfg.ProductCache.TTL != time.Minute {
+		t.Errorf("unexpected product cache defaults %+v", cfg.ProductCache)
+	}
+
+	cfg, err = LoadConfig(writeConfig(t, baseConfig+"productCache:\n  enabled: true\n  size: 50\n  ttl: 5m\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ProductCache.Enabled || cfg.ProductCache.Size != 50 || cfg.ProductCache.TTL != 5*time.Minute {
+		t.Errorf("unexpected product cache config %+v", cfg.ProductCache)
+	}
+}
@@ -0,0 +1,43 @@
+package couponcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Ready checks the validator can answer: the coupon files it scans are readable and the
+// index holds codes. A chain is ready when one of its validators is.
+func Ready(ctx context.Context, v CouponValidator) error {
+	switch v := v.(type) {
+	case *FileValidator:
+		for _, path := range v.filePaths {
+			if _, err := os.Stat(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Index:
+		if len(v.files) == 0 || v.stats.TotalCodes == 0 {
+			return errors.New("coupon index holds no codes")
+		}
+		return nil
+	case *ChainValidator:
+		var errs []error
+		for _, validator := range v.validators {
+			err := Ready(ctx, validator)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	case *instrumentedValidator:
+		return Ready(ctx, v.next)
+	case *DatabaseValidator:
+		return nil // the database is checked on its own
+	default:
+		return fmt.Errorf("unknown coupon validator %T", v)
+	}
+}
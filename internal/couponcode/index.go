@@ -0,0 +1,227 @@
+package couponcode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// CodeWidth is the fixed record width used to store coupon codes in the index.
+	// Shorter codes are right-padded with zero bytes.
+	CodeWidth     = 10
+	minCodeLength = 8
+
+	// minFileMatches is the number of files a code must appear in to be valid.
+	minFileMatches = 2
+)
+
+// code is a fixed-width, zero-padded coupon code record.
+type code [CodeWidth]byte
+
+// encodeCode converts a raw coupon code into its fixed-width record.
+// It returns false when the code can never be valid.
+func encodeCode(s string) (code, bool) {
+	var c code
+	if len(s) < minCodeLength || len(s) > CodeWidth {
+		return c, false
+	}
+
+	copy(c[:], s)
+	return c, true
+}
+
+// codeSet is a sorted, deduplicated sequence of CodeWidth-byte records.
+type codeSet []byte
+
+func (s codeSet) Len() int {
+	return len(s) / CodeWidth
+}
+
+func (s codeSet) at(i int) []byte {
+	return s[i*CodeWidth : (i+1)*CodeWidth]
+}
+
+func (s codeSet) contains(c code) bool {
+	n := s.Len()
+	i := sort.Search(n, func(i int) bool { return bytes.Compare(s.at(i), c[:]) >= 0 })
+	return i < n && bytes.Equal(s.at(i), c[:])
+}
+
+// newCodeSet sorts and deduplicates codes and packs them into a codeSet.
+func newCodeSet(codes []code) codeSet {
+	slices.SortFunc(codes, func(a, b code) int { return bytes.Compare(a[:], b[:]) })
+	codes = slices.Compact(codes)
+
+	set := make(codeSet, len(codes)*CodeWidth)
+	for i := range codes {
+		copy(set[i*CodeWidth:], codes[i][:])
+	}
+
+	return set
+}
+
+type indexedFile struct {
+	path        string
+	codes       codeSet
+	fingerprint fingerprint
+}
+
+// FileStats describes a single source file held in the index.
+type FileStats struct {
+	Path    string `json:"path"`
+	Lines   int    `json:"lines"`
+	Codes   int    `json:"codes"`   // distinct codes kept in the index
+	Skipped int    `json:"skipped"` // lines that can never be a valid code
+}
+
+// IndexStats describes the cost of building and holding the index.
+type IndexStats struct {
+	LoadDuration time.Duration `json:"loadDuration"`
+	MemoryBytes  int64         `json:"memoryBytes"`
+	TotalCodes   int           `json:"totalCodes"`
+	Files        []FileStats   `json:"files"`
+}
+
+// Index is an in-memory, read-only coupon code index holding one sorted code set per
+// source file. It answers the "present in at least two files" rule without touching disk.
+type Index struct {
+	files []indexedFile
+	stats IndexStats
+}
+
+// BuildIndex reads every coupon code file in parallel and builds an Index from them.
+func BuildIndex(ctx context.Context, filePaths []string) (*Index, error) {
+	start := time.Now()
+	idx := &Index{
+		files: make([]indexedFile, len(filePaths)),
+		stats: IndexStats{Files: make([]FileStats, len(filePaths))},
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(filePaths))
+	for i, path := range filePaths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			f, stats, err := indexFile(ctx, path)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			idx.files[i] = f
+			idx.stats.Files[i] = stats
+		}(i, path)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		log.WithCtx(ctx).Error().Msgf("coupon index build failed: %v", err)
+		return nil, err
+	}
+
+	idx.finalizeStats(time.Since(start))
+	log.WithCtx(ctx).Info().Msgf("coupon index built in %s: %d codes across %d files using %d bytes",
+		idx.stats.LoadDuration, idx.stats.TotalCodes, len(idx.files), idx.stats.MemoryBytes)
+	return idx, nil
+}
+
+// indexFile reads the codes of a single coupon code file in a span of its own.
+func indexFile(ctx context.Context, path string) (f indexedFile, stats FileStats, err error) {
+	ctx, span := tracing.Start(ctx, "couponcode.indexFile", attribute.String("coupon.file", path))
+	defer func() { tracing.End(span, err) }()
+
+	fp, err := fingerprintFile(path)
+	if err != nil {
+		return f, stats, fmt.Errorf("failed to fingerprint coupon code file %s: %w", path, err)
+	}
+
+	codes, stats, err := readCodeSet(ctx, path)
+	if err != nil {
+		return f, stats, fmt.Errorf("failed to index coupon code file %s: %w", path, err)
+	}
+
+	span.SetAttributes(attribute.Int("coupon.codes", codes.Len()))
+	return indexedFile{path: path, codes: codes, fingerprint: fp}, stats, nil
+}
+
+func (idx *Index) finalizeStats(loadDuration time.Duration) {
+	idx.stats.LoadDuration = loadDuration
+	idx.stats.TotalCodes = 0
+	idx.stats.MemoryBytes = 0
+	for _, f := range idx.files {
+		idx.stats.TotalCodes += f.codes.Len()
+		idx.stats.MemoryBytes += int64(len(f.codes) + len(f.path))
+	}
+}
+
+func readCodeSet(ctx context.Context, path string) (codeSet, FileStats, error) {
+	stats := FileStats{Path: path}
+	reader, err := openCouponCodeFile(path)
+	if err != nil {
+		return nil, stats, err
+	}
+	defer func() { _ = reader.Close() }()
+
+	var codes []code
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if stats.Lines%100_000 == 0 && ctx.Err() != nil {
+			return nil, stats, ctx.Err()
+		}
+
+		stats.Lines++
+		c, ok := encodeCode(strings.TrimSpace(scanner.Text()))
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+
+		codes = append(codes, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, stats, err
+	}
+
+	set := newCodeSet(codes)
+	stats.Codes = set.Len()
+	return set, stats, nil
+}
+
+// Contains reports whether the code is present in at least two of the indexed files.
+func (idx *Index) Contains(code string) bool {
+	c, ok := encodeCode(code)
+	if !ok {
+		return false
+	}
+
+	matches := 0
+	for _, f := range idx.files {
+		if f.codes.contains(c) {
+			matches++
+			if matches >= minFileMatches {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Stats returns the load time, memory footprint and code counts of the index.
+func (idx *Index) Stats() IndexStats {
+	stats := idx.stats
+	stats.Files = slices.Clone(idx.stats.Files)
+	return stats
+}
@@ -0,0 +1,76 @@
+package couponcode_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/internal/couponcode"
+)
+
+func TestBuildIndex_Contains(t *testing.T) {
+	file1 := createTempFile(t, []string{"ABC12345", "XYZ98765", "ABC12345", "SHORT"})
+	defer os.Remove(file1)
+
+	file2 := createTempGzipFile(t, []string{"ABC12345", "LMN11111", "TENCHARS10"})
+	defer os.Remove(file2)
+
+	file3 := createTempFile(t, []string{" TENCHARS10 ", "QWE22222"})
+	defer os.Remove(file3)
+
+	idx, err := couponcode.BuildIndex(t.Context(), []string{file1, file2, file3})
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+
+	tests := []struct {
+		code     string
+		expected bool
+	}{
+		{"ABC12345", true},
+		{"TENCHARS10", true},
+		{"XYZ98765", false},
+		{"QWE22222", false},
+		{"NOTFOUND", false},
+		{"SHORT", false},
+		{"ABC1234", false},
+		{"ABC12345678", false},
+	}
+	for _, tt := range tests {
+		if got := idx.Contains(tt.code); got != tt.expected {
+			t.Errorf("Contains(%q) = %v, expected %v", tt.code, got, tt.expected)
+		}
+	}
+}
+
+func TestBuildIndex_Stats(t *testing.T) {
+	file1 := createTempFile(t, []string{"ABC12345", "ABC12345", "XYZ98765", "SHORT"})
+	defer os.Remove(file1)
+
+	file2 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file2)
+
+	idx, err := couponcode.BuildIndex(t.Context(), []string{file1, file2})
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+
+	stats := idx.Stats()
+	if stats.TotalCodes != 3 {
+		t.Errorf("expected 3 indexed codes, got %d", stats.TotalCodes)
+	}
+	if stats.MemoryBytes < int64(3*couponcode.CodeWidth) {
+		t.Errorf("expected memory footprint of at least %d bytes, got %d", 3*couponcode.CodeWidth, stats.MemoryBytes)
+	}
+	if len(stats.Files) != 2 {
+		t.Fatalf("expected stats for 2 files, got %d", len(stats.Files))
+	}
+	if f := stats.Files[0]; f.Lines != 4 || f.Codes != 2 || f.Skipped != 1 {
+		t.Errorf("unexpected stats for first file: %+v", f)
+	}
+}
+
+func TestBuildIndex_MissingFile(t *testing.T) {
+	if _, err := couponcode.BuildIndex(t.Context(), []string{"does-not-exist.gz"}); err == nil {
+		t.Error("expected error for missing coupon code file")
+	}
+}
@@ -0,0 +1,289 @@
+package couponcode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	errors2 "errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+)
+
+// Index file layout (all integers little endian):
+//
+//	header   magic [8]byte | version uint32 | code width uint32 | file count uint32
+//	entries  per source file: name length uint16 | base name | size int64 | sha256 [32]byte |
+//	         lines uint64 | skipped uint64 | code count uint64
+//	codes    per source file: code count * code width bytes, sorted and deduplicated
+//	trailer  crc32 (Castagnoli) of everything above uint32
+const indexFileVersion uint32 = 1
+
+var (
+	indexFileMagic = [8]byte{'K', 'A', 'R', 'T', 'C', 'I', 'D', 'X'}
+	crcTable       = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// fingerprint identifies the exact contents of a coupon code source file.
+type fingerprint struct {
+	Size   int64
+	SHA256 [sha256.Size]byte
+}
+
+func fingerprintFile(path string) (fingerprint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fingerprint{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return fingerprint{}, err
+	}
+
+	fp := fingerprint{Size: size}
+	copy(fp.SHA256[:], h.Sum(nil))
+	return fp, nil
+}
+
+// WriteIndexFile persists the index to path. The file is written next to its final
+// location and renamed into place so readers never observe a partial index.
+func WriteIndexFile(path string, idx *Index) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	crc := crc32.New(crcTable)
+	w := bufio.NewWriterSize(io.MultiWriter(tmp, crc), 1<<20)
+	if err := writeIndex(w, idx); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := binary.Write(tmp, binary.LittleEndian, crc.Sum32()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func writeIndex(w io.Writer, idx *Index) error {
+	header := []any{indexFileMagic, indexFileVersion, uint32(CodeWidth), uint32(len(idx.files))}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	for i, f := range idx.files {
+		name := filepath.Base(f.path)
+		stats := idx.stats.Files[i]
+		entry := []any{
+			uint16(len(name)), []byte(name), f.fingerprint.Size, f.fingerprint.SHA256,
+			uint64(stats.Lines), uint64(stats.Skipped), uint64(f.codes.Len()),
+		}
+		for _, v := range entry {
+			if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, f := range idx.files {
+		if _, err := w.Write(f.codes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexFileEntry is the decoded per source file header of an index file.
+type indexFileEntry struct {
+	name        string
+	fingerprint fingerprint
+	lines       uint64
+	skipped     uint64
+	codes       uint64
+}
+
+// ReadIndexFile loads an index file written by WriteIndexFile. The whole file is read
+// in one go and every code set is a sub slice of that buffer, so no per-code decoding
+// happens at boot. filePaths are the configured source files, matched by position.
+func ReadIndexFile(path string, filePaths []string) (*Index, error) {
+	start := time.Now()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: file too short", errors.ErrCouponIndexCorrupt)
+	}
+	body, trailer := data[:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(body, crcTable) != binary.LittleEndian.Uint32(trailer) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errors.ErrCouponIndexCorrupt)
+	}
+
+	r := bytes.NewReader(body)
+	var (
+		magic              [8]byte
+		version, width, nf uint32
+	)
+	for _, v := range []any{&magic, &version, &width, &nf} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrCouponIndexCorrupt, err)
+		}
+	}
+	if magic != indexFileMagic || version != indexFileVersion || width != CodeWidth {
+		return nil, fmt.Errorf("%w: unsupported header (version %d, code width %d)",
+			errors.ErrCouponIndexCorrupt, version, width)
+	}
+	if int(nf) != len(filePaths) {
+		return nil, fmt.Errorf("%w: index has %d files, %d configured",
+			errors.ErrCouponIndexStale, nf, len(filePaths))
+	}
+
+	entries := make([]indexFileEntry, nf)
+	for i := range entries {
+		if err := readIndexFileEntry(r, &entries[i]); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrCouponIndexCorrupt, err)
+		}
+	}
+
+	offset := len(body) - r.Len()
+	idx := &Index{
+		files: make([]indexedFile, nf),
+		stats: IndexStats{Files: make([]FileStats, nf)},
+	}
+	for i, e := range entries {
+		if e.name != filepath.Base(filePaths[i]) {
+			return nil, fmt.Errorf("%w: index entry %d is %s, %s configured",
+				errors.ErrCouponIndexStale, i, e.name, filePaths[i])
+		}
+		if e.codes > uint64(len(body)/CodeWidth) {
+			return nil, fmt.Errorf("%w: code section out of bounds", errors.ErrCouponIndexCorrupt)
+		}
+
+		size := int(e.codes) * CodeWidth
+		if offset+size > len(body) {
+			return nil, fmt.Errorf("%w: code section out of bounds", errors.ErrCouponIndexCorrupt)
+		}
+
+		idx.files[i] = indexedFile{
+			path:        filePaths[i],
+			codes:       codeSet(body[offset : offset+size : offset+size]),
+			fingerprint: e.fingerprint,
+		}
+		idx.stats.Files[i] = FileStats{
+			Path:    filePaths[i],
+			Lines:   int(e.lines),
+			Codes:   int(e.codes),
+			Skipped: int(e.skipped),
+		}
+		offset += size
+	}
+	if offset != len(body) {
+		return nil, fmt.Errorf("%w: trailing data", errors.ErrCouponIndexCorrupt)
+	}
+
+	idx.finalizeStats(time.Since(start))
+	return idx, nil
+}
+
+func readIndexFileEntry(r io.Reader, e *indexFileEntry) error {
+	var nameLen uint16
+	if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil {
+		return err
+	}
+
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return err
+	}
+	e.name = string(name)
+
+	for _, v := range []any{&e.fingerprint.Size, &e.fingerprint.SHA256, &e.lines, &e.skipped, &e.codes} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifyIndexFile loads the index file and checks that it was built from the current
+// contents of filePaths. A source file that is not present is skipped with a warning,
+// which allows shipping the index without the raw coupon code files.
+func VerifyIndexFile(ctx context.Context, path string, filePaths []string) (*Index, error) {
+	idx, err := ReadIndexFile(path, filePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, filePath := range filePaths {
+		fp, err := fingerprintFile(filePath)
+		if errors2.Is(err, os.ErrNotExist) {
+			log.WithCtx(ctx).Warn().Msgf("coupon code file %s not found, trusting index %s", filePath, path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if fp != idx.files[i].fingerprint {
+			return nil, fmt.Errorf("%w: %s changed since the index was built", errors.ErrCouponIndexStale, filePath)
+		}
+	}
+
+	return idx, nil
+}
+
+// LoadOrBuildIndex loads the index from indexPath when it matches the configured source
+// files, otherwise it rebuilds the index from the source files and persists it again.
+// An empty indexPath always builds the index in memory only.
+func LoadOrBuildIndex(ctx context.Context, indexPath string, filePaths []string) (*Index, error) {
+	if indexPath == "" {
+		return BuildIndex(ctx, filePaths)
+	}
+
+	start := time.Now()
+	idx, err := VerifyIndexFile(ctx, indexPath, filePaths)
+	if err == nil {
+		idx.stats.LoadDuration = time.Since(start)
+		log.WithCtx(ctx).Info().Msgf("coupon index loaded from %s in %s: %d codes across %d files using %d bytes",
+			indexPath, idx.stats.LoadDuration, idx.stats.TotalCodes, len(idx.files), idx.stats.MemoryBytes)
+		return idx, nil
+	}
+
+	log.WithCtx(ctx).Warn().Msgf("coupon index %s not usable, rebuilding: %v", indexPath, err)
+	idx, err = BuildIndex(ctx, filePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := WriteIndexFile(indexPath, idx); err != nil {
+		log.WithCtx(ctx).Warn().Msgf("failed to persist coupon index to %s: %v", indexPath, err)
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,92 @@
+package couponcode_test
+
+import (
+	errors2 "errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/internal/couponcode"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+)
+
+func buildTestIndexFile(t *testing.T) (string, []string) {
+	t.Helper()
+	file1 := createTempGzipFile(t, []string{"ABC12345", "XYZ98765"})
+	t.Cleanup(func() { os.Remove(file1) })
+
+	file2 := createTempFile(t, []string{"ABC12345", "LMN11111"})
+	t.Cleanup(func() { os.Remove(file2) })
+
+	files := []string{file1, file2}
+	idx, err := couponcode.BuildIndex(t.Context(), files)
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+
+	indexPath := filepath.Join(t.TempDir(), "coupons.idx")
+	if err := couponcode.WriteIndexFile(indexPath, idx); err != nil {
+		t.Fatalf("WriteIndexFile failed: %v", err)
+	}
+
+	return indexPath, files
+}
+
+func TestIndexFile_RoundTrip(t *testing.T) {
+	indexPath, files := buildTestIndexFile(t)
+
+	idx, err := couponcode.VerifyIndexFile(t.Context(), indexPath, files)
+	if err != nil {
+		t.Fatalf("VerifyIndexFile failed: %v", err)
+	}
+	if !idx.Contains("ABC12345") {
+		t.Error("expected ABC12345 to be valid")
+	}
+	if idx.Contains("LMN11111") {
+		t.Error("expected LMN11111 to be invalid")
+	}
+	if stats := idx.Stats(); stats.TotalCodes != 4 || stats.Files[0].Lines != 2 {
+		t.Errorf("unexpected stats after load: %+v", stats)
+	}
+}
+
+func TestIndexFile_Corrupt(t *testing.T) {
+	indexPath, files := buildTestIndexFile(t)
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(indexPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := couponcode.ReadIndexFile(indexPath, files); !errors2.Is(err, errors.ErrCouponIndexCorrupt) {
+		t.Errorf("expected ErrCouponIndexCorrupt, got %v", err)
+	}
+}
+
+func TestIndexFile_StaleSource(t *testing.T) {
+	indexPath, files := buildTestIndexFile(t)
+
+	if err := os.WriteFile(files[1], []byte("QWE22222\nXYZ98765\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := couponcode.VerifyIndexFile(t.Context(), indexPath, files); !errors2.Is(err, errors.ErrCouponIndexStale) {
+		t.Errorf("expected ErrCouponIndexStale, got %v", err)
+	}
+
+	idx, err := couponcode.LoadOrBuildIndex(t.Context(), indexPath, files)
+	if err != nil {
+		t.Fatalf("LoadOrBuildIndex failed: %v", err)
+	}
+	if !idx.Contains("XYZ98765") || idx.Contains("ABC12345") {
+		t.Error("expected rebuilt index to reflect the changed source file")
+	}
+
+	// the rebuilt index is persisted and valid for the new source files
+	if _, err := couponcode.VerifyIndexFile(t.Context(), indexPath, files); err != nil {
+		t.Errorf("expected persisted index to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package couponcode
+
+import (
+	"context"
+	"time"
+
+	"github.com/malakagl/kart-challenge/internal/metrics"
+)
+
+// instrumentedValidator records the latency and hit, miss and error counts of a validator.
+type instrumentedValidator struct {
+	name string
+	next CouponValidator
+}
+
+func instrument(name string, v CouponValidator) CouponValidator {
+	return &instrumentedValidator{name: name, next: v}
+}
+
+func (v *instrumentedValidator) Validate(ctx context.Context, code string) (bool, error) {
+	start := time.Now()
+	ok, err := v.next.Validate(ctx, code)
+	metrics.CouponValidationDuration.WithLabelValues(v.name).Observe(time.Since(start).Seconds())
+
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case ok:
+		result = "hit"
+	}
+	metrics.CouponValidations.WithLabelValues(v.name, result).Inc()
+
+	return ok, err
+}
@@ -10,39 +10,64 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
-	"time"
 
+	"github.com/malakagl/kart-challenge/internal/tracing"
 	"github.com/malakagl/kart-challenge/pkg/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-var couponCodeFiles []string
-
-func SetCouponCodeFiles(f []string) {
-	couponCodeFiles = f
+// gzipFile closes both the gzip reader and the underlying file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
 }
 
-func worker(ctx context.Context, path, code string, count *atomic.Int32, wg *sync.WaitGroup, cancel context.CancelFunc) {
-	defer wg.Done()
+func (g gzipFile) Close() error {
+	_ = g.Reader.Close()
+	return g.file.Close()
+}
 
+// openCouponCodeFile opens a coupon code file, transparently decompressing .gz files.
+func openCouponCodeFile(path string) (io.ReadCloser, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer func() { _ = f.Close() }()
-
-	var reader io.Reader = f
 
 	// If file ends with .gz → wrap in gzip reader
 	if strings.HasSuffix(strings.ToLower(filepath.Ext(path)), ".gz") {
 		gz, err := gzip.NewReader(f)
 		if err != nil {
-			log.Error().Msgf("Error creating gzip reader: %v", err)
-			return
+			_ = f.Close()
+			return nil, err
 		}
-		defer func() { _ = gz.Close() }()
-		reader = gz
+
+		return gzipFile{Reader: gz, file: f}, nil
 	}
 
+	return f, nil
+}
+
+func worker(ctx context.Context, path, code string, count *atomic.Int32, wg *sync.WaitGroup, cancel context.CancelFunc) {
+	defer wg.Done()
+
+	ctx, span := tracing.Start(ctx, "couponcode.scanFile", attribute.String("coupon.file", path))
+	found := false
+	defer func() {
+		span.SetAttributes(attribute.Bool("coupon.found", found))
+		span.End()
+	}()
+
+	reader, err := openCouponCodeFile(path)
+	if err != nil {
+		log.Error().Msgf("Error opening coupon code file %s: %v", path, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
 		select {
@@ -51,7 +76,8 @@ func worker(ctx context.Context, path, code string, count *atomic.Int32, wg *syn
 			return
 		default:
 			if strings.TrimSpace(scanner.Text()) == code {
-				if count.Add(1) >= 2 { // found in 2 files
+				found = true
+				if count.Add(1) >= minFileMatches { // found in 2 files
 					cancel() // stop all other workers
 					return
 				}
@@ -61,13 +87,10 @@ func worker(ctx context.Context, path, code string, count *atomic.Int32, wg *syn
 	}
 }
 
-func ValidateCouponCode(ctx context.Context, code string) bool {
-	log.WithCtx(ctx).Debug().Msgf("validating coupon code %s", code)
-	defer func(start time.Time) {
-		log.WithCtx(ctx).Debug().Msgf("validated coupon code in %s", time.Since(start).String())
-	}(time.Now())
-
-	if len(code) < 8 || len(code) > 10 {
+// scanFiles reports whether code is present in at least two of filePaths by scanning
+// every file concurrently. Workers stop as soon as the code has been found twice.
+func scanFiles(ctx context.Context, filePaths []string, code string) bool {
+	if len(code) < minCodeLength || len(code) > CodeWidth {
 		return false
 	}
 
@@ -76,11 +99,11 @@ func ValidateCouponCode(ctx context.Context, code string) bool {
 
 	var wg sync.WaitGroup
 	var count atomic.Int32
-	for _, f := range couponCodeFiles {
+	for _, f := range filePaths {
 		wg.Add(1)
 		go worker(ctx, f, code, &count, &wg, cancel)
 	}
 
 	wg.Wait()
-	return count.Load() >= 2
+	return count.Load() >= minFileMatches
 }
@@ -47,7 +47,7 @@ func createTempGzipFile(t *testing.T, lines []string) string {
 	return file.Name()
 }
 
-func TestValidateCouponCode_PlainText(t *testing.T) {
+func TestFileValidator_PlainText(t *testing.T) {
 	// create files
 	file1 := createTempFile(t, []string{"ABC12345", "XYZ98765"})
 	defer os.Remove(file1)
@@ -58,65 +58,76 @@ func TestValidateCouponCode_PlainText(t *testing.T) {
 	file3 := createTempFile(t, []string{"QWE22222"})
 	defer os.Remove(file3)
 
-	couponcode.SetCouponCodeFiles([]string{file1, file2, file3})
+	v := couponcode.NewFileValidator([]string{file1, file2, file3})
 
 	// code present in 2 files → should return true
-	if !couponcode.ValidateCouponCode(t.Context(), "ABC12345") {
+	if !valid(t, v, "ABC12345") {
 		t.Error("Expected true, got false")
 	}
 
 	// code present in 1 file → should return false
-	if couponcode.ValidateCouponCode(t.Context(), "QWE22222") {
+	if valid(t, v, "QWE22222") {
 		t.Error("Expected false, got true")
 	}
 
 	// code not present → should return false
-	if couponcode.ValidateCouponCode(t.Context(), "NOTFOUND") {
+	if valid(t, v, "NOTFOUND") {
 		t.Error("Expected false, got true")
 	}
 
 	// code too short → should return false
-	if couponcode.ValidateCouponCode(t.Context(), "SHORT") {
+	if valid(t, v, "SHORT") {
 		t.Error("Expected false for short code")
 	}
 }
 
-func TestValidateCouponCode_GzipFiles(t *testing.T) {
+func TestFileValidator_GzipFiles(t *testing.T) {
 	file1 := createTempGzipFile(t, []string{"ABC12345", "XYZ98765"})
 	defer os.Remove(file1)
 
 	file2 := createTempGzipFile(t, []string{"ABC12345", "LMN11111"})
 	defer os.Remove(file2)
 
-	couponcode.SetCouponCodeFiles([]string{file1, file2})
+	v := couponcode.NewFileValidator([]string{file1, file2})
 
 	// code present in 2 files → should return true
-	if !couponcode.ValidateCouponCode(t.Context(), "ABC12345") {
+	if !valid(t, v, "ABC12345") {
 		t.Error("Expected true, got false")
 	}
 
 	// code present in 1 file → should return false
-	if couponcode.ValidateCouponCode(t.Context(), "LMN11111") {
+	if valid(t, v, "LMN11111") {
 		t.Error("Expected false, got true")
 	}
 }
 
-func TestValidateCouponCode_MixedFiles(t *testing.T) {
+func TestFileValidator_MixedFiles(t *testing.T) {
 	file1 := createTempFile(t, []string{"ABC12345", "XYZ98765"})
 	defer os.Remove(file1)
 
 	file2 := createTempGzipFile(t, []string{"ABC12345", "LMN11111"})
 	defer os.Remove(file2)
 
-	couponcode.SetCouponCodeFiles([]string{file1, file2})
+	v := couponcode.NewFileValidator([]string{file1, file2})
 
 	// code present in both → should return true
-	if !couponcode.ValidateCouponCode(t.Context(), "ABC12345") {
+	if !valid(t, v, "ABC12345") {
 		t.Error("Expected true, got false")
 	}
 
 	// code present in only one → should return false
-	if couponcode.ValidateCouponCode(t.Context(), "LMN11111") {
+	if valid(t, v, "LMN11111") {
 		t.Error("Expected false, got true")
 	}
 }
+
+// valid asks v whether code is valid, failing the test when it cannot tell.
+func valid(t *testing.T, v couponcode.CouponValidator, code string) bool {
+	t.Helper()
+	ok, err := v.Validate(t.Context(), code)
+	if err != nil {
+		t.Fatalf("unexpected error validating %s: %v", code, err)
+	}
+
+	return ok
+}
@@ -0,0 +1,154 @@
+package couponcode
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/pkg/log"
+)
+
+// Validation strategies selectable through CouponCodeConfig.Validator.
+const (
+	StrategyFile     = "file"
+	StrategyIndex    = "index"
+	StrategyDatabase = "database"
+	StrategyHybrid   = "hybrid"
+)
+
+// CouponValidator decides whether a coupon code is present in at least two coupon code files.
+type CouponValidator interface {
+	Validate(ctx context.Context, code string) (bool, error)
+}
+
+// CodeCounter counts the distinct coupon code files a code was loaded from.
+// repositories.CouponCodeRepo implements it on top of the coupon_codes table.
+type CodeCounter interface {
+	CountFilesByCode(ctx context.Context, code string) (int64, error)
+}
+
+// FileValidator scans the raw coupon code files on every call.
+type FileValidator struct {
+	filePaths []string
+}
+
+func NewFileValidator(filePaths []string) *FileValidator {
+	return &FileValidator{filePaths: filePaths}
+}
+
+func (v *FileValidator) Validate(ctx context.Context, code string) (bool, error) {
+	return scanFiles(ctx, v.filePaths, code), nil
+}
+
+// Validate answers from the in-memory index, so Index is a CouponValidator itself.
+func (idx *Index) Validate(_ context.Context, code string) (bool, error) {
+	return idx.Contains(code), nil
+}
+
+// DatabaseValidator looks codes up in the coupon_codes table.
+type DatabaseValidator struct {
+	counter CodeCounter
+}
+
+func NewDatabaseValidator(c CodeCounter) *DatabaseValidator {
+	return &DatabaseValidator{counter: c}
+}
+
+func (v *DatabaseValidator) Validate(ctx context.Context, code string) (bool, error) {
+	count, err := v.counter.CountFilesByCode(ctx, code)
+	if err != nil {
+		return false, err
+	}
+
+	return count >= minFileMatches, nil
+}
+
+// ChainValidator asks each validator in turn and accepts the code as soon as one of them
+// does. A failing validator is logged and skipped; an error is only returned when no
+// validator could give an answer.
+type ChainValidator struct {
+	validators []CouponValidator
+}
+
+func NewChainValidator(v ...CouponValidator) *ChainValidator {
+	return &ChainValidator{validators: v}
+}
+
+func (c *ChainValidator) Validate(ctx context.Context, code string) (bool, error) {
+	var lastErr error
+	answered := false
+	for _, v := range c.validators {
+		ok, err := v.Validate(ctx, code)
+		if err != nil {
+			log.WithCtx(ctx).Warn().Msgf("coupon validator %T failed: %v", v, err)
+			lastErr = err
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+
+		answered = true
+	}
+
+	if answered {
+		return false, nil
+	}
+
+	return false, lastErr
+}
+
+// NewValidator builds the CouponValidator selected in the configuration. The index is
+// only loaded when the selected strategy needs it. Validators record metrics labelled
+// with their strategy.
+func NewValidator(ctx context.Context, cfg config.CouponCodeConfig, counter CodeCounter) (CouponValidator, error) {
+	strategy := cfg.Validator
+	if strategy == "" {
+		strategy = StrategyIndex
+	}
+
+	if strategy != StrategyHybrid {
+		v, err := newStrategyValidator(ctx, strategy, cfg, counter)
+		if err != nil {
+			return nil, err
+		}
+
+		return instrument(strategy, v), nil
+	}
+
+	chain := cfg.Chain
+	if len(chain) == 0 {
+		chain = []string{StrategyIndex, StrategyDatabase}
+	}
+
+	validators := make([]CouponValidator, 0, len(chain))
+	for _, s := range chain {
+		v, err := newStrategyValidator(ctx, s, cfg, counter)
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, instrument(s, v))
+	}
+
+	return instrument(StrategyHybrid, NewChainValidator(validators...)), nil
+}
+
+func newStrategyValidator(ctx context.Context, strategy string, cfg config.CouponCodeConfig, counter CodeCounter) (CouponValidator, error) {
+	log.WithCtx(ctx).Info().Msgf("using %s coupon code validator", strategy)
+	switch strategy {
+	case StrategyFile:
+		return NewFileValidator(cfg.FilePaths), nil
+	case StrategyIndex:
+		idx, err := LoadOrBuildIndex(ctx, cfg.IndexPath, cfg.FilePaths)
+		if err != nil {
+			return nil, err
+		}
+
+		return idx, nil
+	case StrategyDatabase:
+		return NewDatabaseValidator(counter), nil
+	default:
+		return nil, fmt.Errorf("unknown coupon code validator %q", strategy)
+	}
+}
@@ -0,0 +1,165 @@
+package couponcode_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/internal/couponcode"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type stubCounter struct {
+	count int64
+	err   error
+}
+
+func (s stubCounter) CountFilesByCode(_ context.Context, _ string) (int64, error) {
+	return s.count, s.err
+}
+
+type stubValidator struct {
+	valid bool
+	err   error
+}
+
+func (s stubValidator) Validate(_ context.Context, _ string) (bool, error) {
+	return s.valid, s.err
+}
+
+func TestDatabaseValidator(t *testing.T) {
+	tests := []struct {
+		name     string
+		counter  stubCounter
+		expected bool
+		wantErr  bool
+	}{
+		{name: "found in two files", counter: stubCounter{count: 2}, expected: true},
+		{name: "found in one file", counter: stubCounter{count: 1}},
+		{name: "database error", counter: stubCounter{err: errors.New("db down")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := couponcode.NewDatabaseValidator(tt.counter).Validate(t.Context(), "ABC12345")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestChainValidator(t *testing.T) {
+	failing := stubValidator{err: errors.New("unavailable")}
+	tests := []struct {
+		name       string
+		validators []couponcode.CouponValidator
+		expected   bool
+		wantErr    bool
+	}{
+		{name: "first accepts", validators: []couponcode.CouponValidator{stubValidator{valid: true}, failing}, expected: true},
+		{name: "falls through on error", validators: []couponcode.CouponValidator{failing, stubValidator{valid: true}}, expected: true},
+		{name: "all reject", validators: []couponcode.CouponValidator{stubValidator{}, stubValidator{}}},
+		{name: "reject and error", validators: []couponcode.CouponValidator{stubValidator{}, failing}},
+		{name: "all fail", validators: []couponcode.CouponValidator{failing, failing}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := couponcode.NewChainValidator(tt.validators...).Validate(t.Context(), "ABC12345")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	file1 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file1)
+
+	file2 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file2)
+
+	for _, strategy := range []string{"", couponcode.StrategyFile, couponcode.StrategyIndex, couponcode.StrategyHybrid} {
+		cfg := config.CouponCodeConfig{Validator: strategy, FilePaths: []string{file1, file2}}
+		v, err := couponcode.NewValidator(t.Context(), cfg, stubCounter{})
+		if err != nil {
+			t.Fatalf("NewValidator(%q) failed: %v", strategy, err)
+		}
+
+		if ok, err := v.Validate(t.Context(), "ABC12345"); err != nil || !ok {
+			t.Errorf("%q validator: expected ABC12345 to be valid, got %v, %v", strategy, ok, err)
+		}
+	}
+
+	if _, err := couponcode.NewValidator(t.Context(), config.CouponCodeConfig{Validator: "unknown"}, stubCounter{}); err == nil {
+		t.Error("expected error for unknown validator")
+	}
+}
+
+func TestReady(t *testing.T) {
+	file1 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file1)
+
+	file2 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file2)
+
+	for _, strategy := range []string{couponcode.StrategyFile, couponcode.StrategyIndex, couponcode.StrategyDatabase, couponcode.StrategyHybrid} {
+		cfg := config.CouponCodeConfig{Validator: strategy, FilePaths: []string{file1, file2}}
+		v, err := couponcode.NewValidator(t.Context(), cfg, stubCounter{})
+		if err != nil {
+			t.Fatalf("NewValidator(%q) failed: %v", strategy, err)
+		}
+		if err := couponcode.Ready(t.Context(), v); err != nil {
+			t.Errorf("%q validator: expected ready, got %v", strategy, err)
+		}
+	}
+
+	missing := couponcode.NewFileValidator([]string{file1, file1 + ".missing"})
+	if err := couponcode.Ready(t.Context(), missing); err == nil {
+		t.Error("expected a missing coupon file to fail")
+	}
+	if err := couponcode.Ready(t.Context(), couponcode.NewChainValidator(missing, couponcode.NewDatabaseValidator(stubCounter{}))); err != nil {
+		t.Errorf("expected a chain with a ready validator to be ready, got %v", err)
+	}
+	if err := couponcode.Ready(t.Context(), stubValidator{}); err == nil {
+		t.Error("expected an unknown validator to fail")
+	}
+}
+
+func TestNewValidator_RecordsMetrics(t *testing.T) {
+	file1 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file1)
+
+	file2 := createTempFile(t, []string{"ABC12345"})
+	defer os.Remove(file2)
+
+	cfg := config.CouponCodeConfig{Validator: couponcode.StrategyIndex, FilePaths: []string{file1, file2}}
+	v, err := couponcode.NewValidator(t.Context(), cfg, stubCounter{})
+	if err != nil {
+		t.Fatalf("NewValidator failed: %v", err)
+	}
+
+	hits := metrics.CouponValidations.WithLabelValues(couponcode.StrategyIndex, "hit")
+	misses := metrics.CouponValidations.WithLabelValues(couponcode.StrategyIndex, "miss")
+	hitsBefore, missesBefore := testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	_, _ = v.Validate(t.Context(), "ABC12345")
+	_, _ = v.Validate(t.Context(), "XYZ98765")
+	_, _ = v.Validate(t.Context(), "XYZ98766")
+
+	if got := testutil.ToFloat64(hits) - hitsBefore; got != 1 {
+		t.Errorf("expected 1 hit, got %v", got)
+	}
+	if got := testutil.ToFloat64(misses) - missesBefore; got != 2 {
+		t.Errorf("expected 2 misses, got %v", got)
+	}
+}
@@ -51,3 +51,20 @@ func Connect(ctx context.Context, cfg *config.DatabaseConfig) (*gorm.DB, error)
 
 	return dbInstance, nil
 }
+
+// Close closes the connection pool, the next Connect opens a new one.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if dbInstance == nil {
+		return nil
+	}
+
+	sqlDB, err := dbInstance.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+
+	dbInstance = nil
+	return sqlDB.Close()
+}
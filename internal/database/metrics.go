@@ -0,0 +1,63 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "metrics:query_start"
+
+// Instrument observes the duration of every GORM query and registers the connection
+// pool stats of db with the metrics registry.
+func Instrument(db *gorm.DB, dbName string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	are := prometheus.AlreadyRegisteredError{}
+	if err := metrics.Registry.Register(collectors.NewDBStatsCollector(sqlDB, dbName)); err != nil && !errors.As(err, &are) {
+		return err
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:before_create", startQuery),
+		cb.Create().After("gorm:create").Register("metrics:after_create", observeQuery("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", startQuery),
+		cb.Query().After("gorm:query").Register("metrics:after_query", observeQuery("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", startQuery),
+		cb.Update().After("gorm:update").Register("metrics:after_update", observeQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", startQuery),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", observeQuery("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", startQuery),
+		cb.Row().After("gorm:row").Register("metrics:after_row", observeQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", startQuery),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", observeQuery("raw")),
+	)
+}
+
+func startQuery(tx *gorm.DB) {
+	tx.InstanceSet(queryStartKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(queryStartKey)
+		start, isTime := v.(time.Time)
+		if !ok || !isTime {
+			return
+		}
+
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		metrics.DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
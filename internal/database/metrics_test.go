@@ -0,0 +1,50 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	db, mock := testutil.NewMockDB(t)
+	if err := Instrument(db, "test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "products"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	var rows []struct{ ID uint }
+	if err := db.Table("products").Find(&rows).Error; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var queries uint64
+	poolStats := false
+	for _, f := range families {
+		poolStats = poolStats || f.GetName() == "go_sql_open_connections"
+		if f.GetName() != "kart_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["operation"] == "query" && labels["table"] == "products" {
+				queries = m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	if queries != 1 {
+		t.Errorf("expected one observed products query, got %d", queries)
+	}
+	if !poolStats {
+		t.Error("expected the pool stats to be registered")
+	}
+}
@@ -1,23 +1,29 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/malakagl/kart-challenge/internal/config"
 	"github.com/malakagl/kart-challenge/pkg/log"
+	"gorm.io/gorm"
 )
 
+const migrationsURL = "file://db/migrations"
+
 func RunMigrations(cfg config.DatabaseConfig) error {
 	dsn := fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
 		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name, cfg.SSLMode,
 	)
 	log.Debug().Msgf("database migrations started: %v", dsn)
-	m, err := migrate.New("file://db/migrations", dsn)
+	m, err := migrate.New(migrationsURL, dsn)
 	if err != nil {
 		log.Error().Msgf("Failed to create migration instance: %v", err)
 		return err
@@ -30,3 +36,40 @@ func RunMigrations(cfg config.DatabaseConfig) error {
 
 	return nil
 }
+
+// LatestMigrationVersion is the version of the newest migration shipped with the service.
+func LatestMigrationVersion() (uint, error) {
+	src, err := source.Open(migrationsURL)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = src.Close() }()
+
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// MigrationVersion returns the schema version of the database and whether the last
+// migration failed half way.
+func MigrationVersion(db *gorm.DB) func(ctx context.Context) (uint, bool, error) {
+	return func(ctx context.Context) (uint, bool, error) {
+		var row struct {
+			Version uint
+			Dirty   bool
+		}
+		err := db.WithContext(ctx).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&row).Error
+		return row.Version, row.Dirty, err
+	}
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingPlugin(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	db, mock := testutil.NewMockDB(t)
+	if err := db.Use(TracingPlugin{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "products"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	var rows []struct{ ID uint }
+	if err := db.WithContext(ctx).Table("products").Find(&rows).Error; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the query and parent spans, got %d", len(spans))
+	}
+	query := spans[0]
+	if query.Name() != "gorm.query" {
+		t.Errorf("expected a gorm.query span, got %q", query.Name())
+	}
+	if query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the query span to be a child of the context span")
+	}
+	attrs := map[string]string{}
+	for _, attr := range query.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs["db.collection.name"] != "products" || attrs["db.system.name"] != "postgres" {
+		t.Errorf("unexpected span attributes: %v", attrs)
+	}
+}
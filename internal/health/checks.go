@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// Database pings the database.
+func Database(db *gorm.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// MigrationVersioner reports the schema version of the database.
+type MigrationVersioner func(ctx context.Context) (version uint, dirty bool, err error)
+
+// Migrations checks the database schema is at least at the version of the shipped
+// migrations, so a pod is not sent traffic while its schema is behind or a migration
+// failed half way. A newer schema passes, it is expected while a rollout replaces old pods.
+func Migrations(current MigrationVersioner, expected uint) CheckFunc {
+	return func(ctx context.Context) error {
+		version, dirty, err := current(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed and left the schema dirty", version)
+		}
+		if version < expected {
+			return fmt.Errorf("schema version %d, expected at least %d", version, expected)
+		}
+
+		return nil
+	}
+}
+
+// Files checks the files exist and are not empty.
+func Files(paths []string) CheckFunc {
+	return func(context.Context) error {
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() || info.Size() == 0 {
+				return fmt.Errorf("%s is not a regular non-empty file", path)
+			}
+		}
+
+		return nil
+	}
+}
+
+// DiskSpace checks the file system of dir has at least minFree bytes available.
+func DiskSpace(dir string, minFree uint64) CheckFunc {
+	return func(context.Context) error {
+		free, err := freeBytes(dir)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%d MiB free in %s, need %d MiB", free>>20, dir, minFree>>20)
+		}
+
+		return nil
+	}
+}
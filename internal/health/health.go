@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of checks and reports
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// defaultCheckTimeout bounds checks registered without their own timeout.
+const defaultCheckTimeout = 2 * time.Second
+
+// CheckFunc reports a dependency as unhealthy by returning an error.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of all checks of a registry.
+type Report struct {
+	Status    string            `json:"status"`
+	CheckedAt time.Time         `json:"checkedAt"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// Healthy tells whether every check passed.
+func (r *Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Registry runs named checks. Reports are cached for the TTL so frequent probes do not
+// hit the dependencies on every call.
+type Registry struct {
+	ttl      time.Duration
+	now      func() time.Time
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	checks []check
+	cached *Report
+}
+
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{ttl: ttl, now: time.Now}
+}
+
+// Register adds a check, a zero timeout uses the default of 2s.
+func (r *Registry) Register(name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, timeout: timeout, fn: fn})
+	r.cached = nil
+}
+
+// Drain makes the registry report failure from now on, without waiting for the cache.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Draining tells whether Drain was called.
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Check runs the checks concurrently, or returns the cached report while it is fresh.
+// Checks are not cancelled with ctx since their report is shared by later callers.
+func (r *Registry) Check(ctx context.Context) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if r.cached != nil && now.Sub(r.cached.CheckedAt) < r.ttl {
+		return r.withDraining(r.cached)
+	}
+
+	report := &Report{Status: StatusOK, CheckedAt: now, Checks: make(map[string]Result, len(r.checks))}
+	results := make([]Result, len(r.checks))
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(context.WithoutCancel(ctx), c)
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	r.cached = report
+	return r.withDraining(report)
+}
+
+// withDraining marks a copy of the report as failed while the server shuts down.
+func (r *Registry) withDraining(report *Report) *Report {
+	if !r.Draining() {
+		return report
+	}
+
+	drained := *report
+	drained.Status = StatusFail
+	drained.Checks = make(map[string]Result, len(report.Checks)+1)
+	maps.Copy(drained.Checks, report.Checks)
+	drained.Checks["shutdown"] = Result{Status: StatusFail, Error: "server is shutting down"}
+	return &drained
+}
+
+func run(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	// the check runs on its own so one ignoring the context cannot hold the report up
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", c.timeout)
+	}
+
+	res := Result{Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+
+	return res
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry(5 * time.Second)
+	r.now = func() time.Time { return now }
+
+	calls := 0
+	dbErr := errors.New("connection refused")
+	r.Register("database", time.Second, func(context.Context) error {
+		calls++
+		return dbErr
+	})
+	r.Register("files", time.Second, func(context.Context) error { return nil })
+
+	report := r.Check(t.Context())
+	if report.Healthy() || report.Checks["database"].Error != "connection refused" || report.Checks["files"].Status != StatusOK {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	dbErr = nil
+	if report := r.Check(t.Context()); report.Healthy() || calls != 1 {
+		t.Errorf("expected the cached report, got %+v after %d calls", report, calls)
+	}
+
+	now = now.Add(5 * time.Second)
+	if report := r.Check(t.Context()); !report.Healthy() || calls != 2 {
+		t.Errorf("expected a fresh healthy report, got %+v after %d calls", report, calls)
+	}
+}
+
+func TestRegistry_CheckTimeoutAndPanic(t *testing.T) {
+	r := NewRegistry(0)
+	block := make(chan struct{})
+	defer close(block)
+	r.Register("stuck", 20*time.Millisecond, func(context.Context) error {
+		<-block // ignores the context
+		return nil
+	})
+	r.Register("broken", 0, func(context.Context) error { panic("nil map") })
+
+	report := r.Check(t.Context())
+	if !strings.Contains(report.Checks["stuck"].Error, "timed out") {
+		t.Errorf("expected a timeout, got %+v", report.Checks["stuck"])
+	}
+	if !strings.Contains(report.Checks["broken"].Error, "panicked") {
+		t.Errorf("expected a panic, got %+v", report.Checks["broken"])
+	}
+}
+
+func TestRegistry_Drain(t *testing.T) {
+	r := NewRegistry(time.Hour)
+	r.Register("database", 0, func(context.Context) error { return nil })
+	if report := r.Check(t.Context()); !report.Healthy() {
+		t.Fatalf("expected a healthy report, got %+v", report)
+	}
+
+	r.Drain()
+	report := r.Check(t.Context())
+	if report.Healthy() || report.Checks["shutdown"].Status != StatusFail || report.Checks["database"].Status != StatusOK {
+		t.Errorf("expected draining to fail the cached report, got %+v", report)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint
+		dirty   bool
+		err     error
+		wantErr bool
+	}{
+		{name: "current", version: 9},
+		{name: "behind", version: 8, wantErr: true},
+		{name: "ahead", version: 10},
+		{name: "dirty", version: 9, dirty: true, wantErr: true},
+		{name: "query error", err: errors.New("no table"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := Migrations(func(context.Context) (uint, bool, error) { return tt.version, tt.dirty, tt.err }, 9)
+			if err := check(t.Context()); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFilesAndDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	full := filepath.Join(dir, "codes.txt")
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(full, []byte("HAPPYHRS\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Files([]string{full})(t.Context()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Files([]string{full, empty})(t.Context()); err == nil {
+		t.Error("expected an empty file to fail")
+	}
+	if err := Files([]string{filepath.Join(dir, "missing.txt")})(t.Context()); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+
+	if err := DiskSpace(dir, 1)(t.Context()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := DiskSpace(dir, 1<<62)(t.Context()); err == nil {
+		t.Error("expected too little disk space")
+	}
+}
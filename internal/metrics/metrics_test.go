@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	OrdersCreated.WithLabelValues("USD").Inc()
+	OrderRevenue.WithLabelValues("USD").Add(19.5)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected the text format, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	body, _ := io.ReadAll(w.Body)
+	for _, want := range []string{
+		`kart_orders_created_total{currency="USD"} 1`,
+		`kart_order_revenue_total{currency="USD"} 19.5`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %q in the exposition", want)
+		}
+	}
+}
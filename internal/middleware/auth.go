@@ -1,37 +1,113 @@
 package middleware
 
 import (
+	"context"
+	errors2 "errors"
 	"net/http"
+	"strings"
 
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/pkg/constants"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
 )
 
-func AuthenticationMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.Header.Get("api_key")
-		if apiKey == "" || !isValidApiKey(apiKey, r.Method, r.RequestURI) {
-			response.Error(w, http.StatusUnauthorized, "AuthError", http.StatusText(http.StatusUnauthorized))
-			return
-		}
+const (
+	APIKeyHeader        = "api_key"
+	AuthorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
 
-		next.ServeHTTP(w, r)
-	})
+// Authenticator resolves the credentials of a request to a principal.
+// auth.APIKeyAuthenticator and auth.JWTAuthenticator implement it.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credentials string) (*auth.Principal, error)
 }
 
-type accessKey struct {
-	Method string
-	Uri    string
+// unauthorized are the authentication errors reported to the caller with 401.
+var unauthorized = []error{
+	errors.ErrInvalidAPIKey,
+	errors.ErrAPIKeyExpired,
+	errors.ErrInvalidToken,
+	errors.ErrTokenExpired,
 }
 
-var apiKeyMap = map[accessKey]string{
-	{Method: http.MethodPost, Uri: "/order"}: "create_order",
+// Authentication rejects requests without valid credentials with 401 and stores the
+// principal in the request context. A nil authenticator disables its kind of credentials:
+// apiKeys checks the api_key header, bearer the Authorization: Bearer header.
+func Authentication(apiKeys, bearer Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			authenticator, credentials, isBearer := credentialsOf(r, apiKeys, bearer)
+			if credentials == "" {
+				if bearer != nil {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+				}
+				response.Error(w, http.StatusUnauthorized, "AuthError", http.StatusText(http.StatusUnauthorized))
+				return
+			}
+
+			principal, err := authenticator.Authenticate(ctx, credentials)
+			for _, e := range unauthorized {
+				if errors2.Is(err, e) {
+					if isBearer {
+						w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					}
+					response.Error(w, http.StatusUnauthorized, "AuthError", err.Error())
+					return
+				}
+			}
+			if err != nil {
+				log.WithCtx(ctx).Error().Msgf("Error authenticating request: %v", err)
+				response.Error(w, http.StatusInternalServerError, "AuthError", http.StatusText(http.StatusInternalServerError))
+				return
+			}
+
+			ctx = auth.WithPrincipal(ctx, principal)
+			ctx = context.WithValue(ctx, constants.ClientIDKey, principal.ID)
+			if principal.CustomerID != "" {
+				ctx = context.WithValue(ctx, constants.CustomerIDKey, principal.CustomerID)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
-// Replace with actual API key validation logic
-func isValidApiKey(key, reqMethod, reqURI string) bool {
-	if v, ok := apiKeyMap[accessKey{Method: reqMethod, Uri: reqURI}]; ok {
-		return v == key
+// credentialsOf picks the credentials of the request, a bearer token wins over an API key.
+func credentialsOf(r *http.Request, apiKeys, bearer Authenticator) (Authenticator, string, bool) {
+	if bearer != nil {
+		if h := r.Header.Get(AuthorizationHeader); len(h) > len(bearerPrefix) && strings.EqualFold(h[:len(bearerPrefix)], bearerPrefix) {
+			return bearer, strings.TrimSpace(h[len(bearerPrefix):]), true
+		}
 	}
+	if apiKeys != nil {
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			return apiKeys, key, false
+		}
+	}
+
+	return nil, "", false
+}
 
-	return key == "apitest" // default key
+// RequireScope rejects requests whose principal was not granted the scope with 403.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.FromContext(r.Context())
+			if principal == nil {
+				response.Error(w, http.StatusUnauthorized, "AuthError", http.StatusText(http.StatusUnauthorized))
+				return
+			}
+
+			if !principal.HasScope(scope) {
+				log.WithCtx(r.Context()).Error().Msgf("Principal %s lacks scope %s", principal.ID, scope)
+				response.Error(w, http.StatusForbidden, "AuthError", "missing scope "+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
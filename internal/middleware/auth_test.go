@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	errors2 "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/pkg/constants"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+)
+
+// staticAuthenticator implements Authenticator for testing
+type staticAuthenticator map[string]*auth.Principal
+
+func (s staticAuthenticator) Authenticate(_ context.Context, key string) (*auth.Principal, error) {
+	switch key {
+	case "broken":
+		return nil, errors2.New("db down")
+	case "expired":
+		return nil, errors.ErrAPIKeyExpired
+	}
+	if p, ok := s[key]; ok {
+		return p, nil
+	}
+
+	return nil, errors.ErrInvalidAPIKey
+}
+
+func TestAuthentication(t *testing.T) {
+	authenticator := staticAuthenticator{
+		"reader": {ID: "key-1", Scopes: []string{auth.ScopeReadOrders}},
+		"admin":  {ID: "key-2", Scopes: []string{auth.ScopeAdmin}},
+	}
+
+	var clientID string
+	handler := Authentication(authenticator, nil)(RequireScope(auth.ScopeCreateOrder)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID, _ = r.Context().Value(constants.ClientIDKey).(string)
+			w.WriteHeader(http.StatusOK)
+		})))
+
+	tests := []struct {
+		name           string
+		key            string
+		expectedStatus int
+	}{
+		{name: "missing key", expectedStatus: http.StatusUnauthorized},
+		{name: "unknown key", key: "unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "expired key", key: "expired", expectedStatus: http.StatusUnauthorized},
+		{name: "lookup error", key: "broken", expectedStatus: http.StatusInternalServerError},
+		{name: "missing scope", key: "reader", expectedStatus: http.StatusForbidden},
+		{name: "admin has every scope", key: "admin", expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/order", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if clientID != "key-2" {
+		t.Errorf("expected client ID of the API key, got %q", clientID)
+	}
+}
+
+// tokenAuthenticator implements Authenticator for testing bearer tokens
+type tokenAuthenticator func(token string) (*auth.Principal, error)
+
+func (f tokenAuthenticator) Authenticate(_ context.Context, token string) (*auth.Principal, error) {
+	return f(token)
+}
+
+func TestAuthentication_Bearer(t *testing.T) {
+	apiKeys := staticAuthenticator{"key": {ID: "key-1", Scopes: []string{auth.ScopeCreateOrder}}}
+	bearer := tokenAuthenticator(func(token string) (*auth.Principal, error) {
+		switch token {
+		case "valid":
+			return &auth.Principal{ID: "cust-1", CustomerID: "cust-1", Scopes: []string{auth.ScopeCreateOrder}}, nil
+		case "expired":
+			return nil, errors.ErrTokenExpired
+		}
+		return nil, errors.ErrInvalidToken
+	})
+
+	var clientID, customerID string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, _ = r.Context().Value(constants.ClientIDKey).(string)
+		customerID, _ = r.Context().Value(constants.CustomerIDKey).(string)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name               string
+		apiKeys, bearer    Authenticator
+		authorization, key string
+		expectedStatus     int
+		expectedCustomer   string
+		expectedChallenge  string
+	}{
+		{name: "valid token", bearer: bearer, authorization: "Bearer valid", expectedStatus: http.StatusOK, expectedCustomer: "cust-1"},
+		{name: "scheme is case insensitive", bearer: bearer, authorization: "bearer valid", expectedStatus: http.StatusOK, expectedCustomer: "cust-1"},
+		{name: "invalid token", bearer: bearer, authorization: "Bearer forged", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer error="invalid_token"`},
+		{name: "expired token", bearer: bearer, authorization: "Bearer expired", expectedStatus: http.StatusUnauthorized, expectedChallenge: `Bearer error="invalid_token"`},
+		{name: "missing token", bearer: bearer, expectedStatus: http.StatusUnauthorized, expectedChallenge: "Bearer"},
+		{name: "api key in jwt mode", bearer: bearer, key: "key", expectedStatus: http.StatusUnauthorized, expectedChallenge: "Bearer"},
+		{name: "basic auth", bearer: bearer, authorization: "Basic dXNlcg==", expectedStatus: http.StatusUnauthorized, expectedChallenge: "Bearer"},
+		{name: "api key in both mode", apiKeys: apiKeys, bearer: bearer, key: "key", expectedStatus: http.StatusOK},
+		{name: "token wins over api key", apiKeys: apiKeys, bearer: bearer, authorization: "Bearer valid", key: "unknown", expectedStatus: http.StatusOK, expectedCustomer: "cust-1"},
+		{name: "token in apikey mode", apiKeys: apiKeys, authorization: "Bearer valid", expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientID, customerID = "", ""
+			req := httptest.NewRequest(http.MethodPost, "/order", nil)
+			if tt.authorization != "" {
+				req.Header.Set(AuthorizationHeader, tt.authorization)
+			}
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			Authentication(tt.apiKeys, tt.bearer)(next).ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); challenge != tt.expectedChallenge {
+				t.Errorf("expected challenge %q, got %q", tt.expectedChallenge, challenge)
+			}
+			if customerID != tt.expectedCustomer {
+				t.Errorf("expected customer ID %q, got %q", tt.expectedCustomer, customerID)
+			}
+			if tt.expectedStatus == http.StatusOK && clientID == "" {
+				t.Errorf("expected a client ID")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/util"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// IdempotencyStore keeps the responses of requests sent with an Idempotency-Key header.
+// repositories.IdempotencyRepo implements it.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, rec *db.IdempotencyKey) (*db.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, clientID, key string, statusCode int, body []byte) error
+	Release(ctx context.Context, clientID, key string) error
+}
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key header and body. Reusing a key with a different body is rejected with
+// 422 and a retry that arrives while the first request is still running gets 409.
+// Server errors are not stored, so the client can retry them with the same key. Bodies
+// over 1 MiB are rejected with 413, as the whole body has to be hashed.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			if len(key) > maxIdempotencyKeyLength {
+				response.Error(w, http.StatusBadRequest, "Invalid idempotency key", "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+			if err != nil {
+				log.WithCtx(ctx).Error().Msgf("Error reading request body: %v", err)
+				response.Error(w, http.StatusBadRequest, "Invalid request body", err.Error())
+				return
+			}
+			if len(body) > maxIdempotentRequestBytes {
+				response.Error(w, http.StatusRequestEntityTooLarge, "Request body too large",
+					"Requests with an Idempotency-Key are limited to 1 MiB")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.Sum256(body)
+			rec := &db.IdempotencyKey{
+				ClientID:    util.ClientID(ctx),
+				Key:         key,
+				RequestHash: hex.EncodeToString(sum[:]),
+				ExpiresAt:   time.Now().Add(ttl),
+			}
+			existing, reserved, err := store.Reserve(ctx, rec)
+			if err != nil {
+				response.Error(w, http.StatusInternalServerError, "Idempotency error", err.Error())
+				return
+			}
+
+			if !reserved {
+				replay(w, r, existing, rec.RequestHash)
+				return
+			}
+
+			// the response is already sent, do not let a cancelled request lose the record
+			storeCtx := context.WithoutCancel(ctx)
+
+			// a handler that panics never completes the key, free it so retries do not get
+			// 409 until it expires. The panic keeps unwinding to the Recovery middleware.
+			served := false
+			defer func() {
+				if !served {
+					_ = store.Release(storeCtx, rec.ClientID, key)
+				}
+			}()
+
+			var captured bytes.Buffer
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&captured)
+			next.ServeHTTP(ww, r)
+			served = true
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if status >= http.StatusInternalServerError {
+				_ = store.Release(storeCtx, rec.ClientID, key)
+				return
+			}
+			_ = store.Complete(storeCtx, rec.ClientID, key, status, captured.Bytes())
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, r *http.Request, existing *db.IdempotencyKey, requestHash string) {
+	ctx := r.Context()
+	if existing.RequestHash != requestHash {
+		log.WithCtx(ctx).Error().Msgf("Idempotency key %s reused with a different request body", existing.Key)
+		response.Error(w, http.StatusUnprocessableEntity, "Idempotency key reused",
+			"Idempotency-Key was already used with a different request body")
+		return
+	}
+
+	if existing.StatusCode == nil {
+		response.Error(w, http.StatusConflict, "Request in progress",
+			"A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	log.WithCtx(ctx).Info().Msgf("Replaying response for idempotency key %s", existing.Key)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(*existing.StatusCode)
+	_, _ = w.Write(existing.ResponseBody)
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+)
+
+// memoryIdempotencyStore implements IdempotencyStore for testing
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	recs map[string]*db.IdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{recs: map[string]*db.IdempotencyKey{}}
+}
+
+func (m *memoryIdempotencyStore) Reserve(_ context.Context, rec *db.IdempotencyKey) (*db.IdempotencyKey, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.recs[rec.ClientID+rec.Key]; ok && existing.ExpiresAt.After(time.Now()) {
+		return existing, false, nil
+	}
+
+	m.recs[rec.ClientID+rec.Key] = rec
+	return rec, true, nil
+}
+
+func (m *memoryIdempotencyStore) Complete(_ context.Context, clientID, key string, statusCode int, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := m.recs[clientID+key]
+	rec.StatusCode = &statusCode
+	rec.ResponseBody = body
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Release(_ context.Context, clientID, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.recs, clientID+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+	handler := Idempotency(newMemoryIdempotencyStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"order":` + strconv.Itoa(calls) + `}`))
+	}))
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-1", `{"items":[]}`)
+	if first.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("expected first request to be processed, got status %d and %d calls", first.Code, calls)
+	}
+
+	replayed := send("key-1", `{"items":[]}`)
+	if calls != 1 {
+		t.Errorf("expected replay not to call the handler, got %d calls", calls)
+	}
+	if replayed.Code != http.StatusOK || replayed.Body.String() != first.Body.String() {
+		t.Errorf("expected replayed response %q, got %d %q", first.Body.String(), replayed.Code, replayed.Body.String())
+	}
+	if replayed.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("expected replayed response to be marked")
+	}
+
+	if w := send("key-1", `{"items":[1]}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for reused key with a different body, got %d", w.Code)
+	}
+
+	send("", `{"items":[]}`)
+	send("", `{"items":[]}`)
+	if calls != 3 {
+		t.Errorf("expected requests without key to always be processed, got %d calls", calls)
+	}
+
+	status = http.StatusInternalServerError
+	send("key-2", `{"items":[]}`)
+	status = http.StatusOK
+	if w := send("key-2", `{"items":[]}`); w.Code != http.StatusOK || calls != 5 {
+		t.Errorf("expected server errors not to be stored, got status %d and %d calls", w.Code, calls)
+	}
+
+	if w := send(strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a too long key, got %d", w.Code)
+	}
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	_, _, _ = store.Reserve(context.Background(), &db.IdempotencyKey{
+		ClientID:    "anonymous",
+		Key:         "key-1",
+		RequestHash: "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", // sha256 of "{}"
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not be called while the key is in progress")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the first request is in progress, got %d", w.Code)
+	}
+}
+
+func TestIdempotency_ReleasesKeyOnPanic(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to reach the caller")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	if len(store.recs) != 0 {
+		t.Errorf("expected the key to be released after a panic, got %d stored keys", len(store.recs))
+	}
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	handler := Idempotency(newMemoryIdempotencyStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not be called for a too large body")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(strings.Repeat("x", maxIdempotentRequestBytes+1)))
+	req.Header.Set(IdempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a body over the limit, got %d", w.Code)
+	}
+}
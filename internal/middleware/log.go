@@ -1,19 +1,118 @@
 package middleware
 
 import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"time"
 
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/malakagl/kart-challenge/internal/auth"
 	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/rs/zerolog"
 )
 
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		startTime := time.Now()
-		defer func() {
-			log.WithCtx(r.Context()).Info().Msgf("Request %s %s %s processed in %s", r.Method, r.URL.Path, r.RemoteAddr, time.Since(startTime))
-		}()
+// commonLogTime is the timestamp layout of the Common Log Format.
+const commonLogTime = "02/Jan/2006:15:04:05 -0700"
 
-		next.ServeHTTP(w, r)
-	})
+// AccessLog logs every request with its status, size, duration, route pattern and
+// client. Server errors are logged at error level, client errors at warn level and the
+// rest at info level. Only a sampleRate share of 2xx responses is logged. When common is
+// not nil every request is also written to it in the Common Log Format, unsampled. It
+// runs before Authentication so rejected requests are logged too. A request whose handler
+// panics is logged with status 500 before the panic continues to Recovery.
+func AccessLog(sampleRate float64, common io.Writer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			r = r.WithContext(auth.WithPrincipalSlot(r.Context()))
+			defer func() {
+				rec := recover()
+				entry := accessLogEntry{
+					request:  r,
+					status:   statusOf(ww),
+					bytes:    ww.BytesWritten(),
+					start:    start,
+					duration: time.Since(start),
+					client:   "-",
+				}
+				if rec != nil {
+					entry.status = http.StatusInternalServerError
+				}
+				if principal := auth.FromContext(r.Context()); principal != nil {
+					entry.client = principal.ID
+				}
+				entry.write(sampleRate, common)
+				if rec != nil {
+					panic(rec)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+type accessLogEntry struct {
+	request  *http.Request
+	status   int
+	bytes    int
+	start    time.Time
+	duration time.Duration
+	client   string // principal ID, - when not authenticated
+}
+
+// write logs the entry, 2xx entries only for a sampleRate share, and writes it to common
+// when it is not nil.
+func (e accessLogEntry) write(sampleRate float64, common io.Writer) {
+	if common != nil {
+		_, _ = io.WriteString(common, e.commonLog())
+	}
+	if e.status/100 == 2 && sampleRate < 1 && rand.Float64() >= sampleRate {
+		return
+	}
+
+	e.event().Msgf("%s %s %d", e.request.Method, e.request.URL.Path, e.status)
+}
+
+func (e accessLogEntry) event() *zerolog.Event {
+	logger := log.WithCtx(e.request.Context())
+	var ev *zerolog.Event
+	switch {
+	case e.status >= http.StatusInternalServerError:
+		ev = logger.Error()
+	case e.status >= http.StatusBadRequest:
+		ev = logger.Warn()
+	default:
+		ev = logger.Info()
+	}
+
+	return ev.Str("method", e.request.Method).
+		Str("path", e.request.URL.Path).
+		Str("route", servedRoute(e.request)).
+		Int("status", e.status).
+		Int("bytes", e.bytes).
+		Dur("duration", e.duration).
+		Str("remoteAddr", e.request.RemoteAddr).
+		Str("userAgent", e.request.UserAgent()).
+		Str("client", e.client)
+}
+
+// commonLog formats the entry as a Common Log Format line, with the principal ID as the
+// authenticated user.
+func (e accessLogEntry) commonLog() string {
+	host, _, err := net.SplitHostPort(e.request.RemoteAddr)
+	if err != nil {
+		host = e.request.RemoteAddr
+	}
+	size := "-"
+	if e.bytes > 0 {
+		size = fmt.Sprint(e.bytes)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s\n", host, e.client, e.start.Format(commonLogTime),
+		e.request.Method, e.request.URL.RequestURI(), e.request.Proto, e.status, size)
 }
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/rs/zerolog"
+)
+
+// captureLogs sends the service log to a buffer for the duration of t.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	prev := log.Logger
+	t.Cleanup(func() { log.Logger = prev })
+
+	var buf bytes.Buffer
+	log.Logger = zerolog.New(&buf)
+	return &buf
+}
+
+func accessLogRouter(sampleRate float64, common io.Writer) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(AccessLog(sampleRate, common))
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := r.Header.Get("X-Client"); id != "" {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: id}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.Get("/product", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+	r.Get("/order/{orderID}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("oops"))
+	})
+	return r
+}
+
+func TestAccessLog(t *testing.T) {
+	logs := captureLogs(t)
+	r := accessLogRouter(1, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/7", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Client", "key-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON log entry, got %q: %v", logs.String(), err)
+	}
+	want := map[string]any{
+		"level":     "error",
+		"method":    "GET",
+		"path":      "/order/7",
+		"route":     "/order/{orderID}",
+		"status":    float64(503),
+		"bytes":     float64(4),
+		"userAgent": "test-agent",
+		"client":    "key-1",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("expected %s %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["duration"]; !ok {
+		t.Error("expected the duration to be logged")
+	}
+}
+
+func TestAccessLog_SamplesSuccess(t *testing.T) {
+	logs := captureLogs(t)
+	r := accessLogRouter(0, nil)
+
+	for range 10 {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/product", nil))
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected 2xx requests to be sampled out, got %q", logs.String())
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if !strings.Contains(logs.String(), `"level":"warn"`) || !strings.Contains(logs.String(), `"status":404`) {
+		t.Errorf("expected client errors to be logged regardless of sampling, got %q", logs.String())
+	}
+}
+
+func TestAccessLog_CommonLogFormat(t *testing.T) {
+	captureLogs(t)
+	var common bytes.Buffer
+	r := accessLogRouter(0, &common)
+
+	req := httptest.NewRequest(http.MethodGet, "/product?limit=5", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Client", "key-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	lines := strings.Split(strings.TrimSpace(common.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a common log line per request, got %q", common.String())
+	}
+	clf := regexp.MustCompile(`^10\.0\.0\.1 - key-1 \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /product\?limit=5 HTTP/1\.1" 200 2$`)
+	if !clf.MatchString(lines[0]) {
+		t.Errorf("unexpected common log line %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "192.0.2.1 - - [") || !strings.HasSuffix(lines[1], `"GET /missing HTTP/1.1" 404 19`) {
+		t.Errorf("unexpected common log line %q", lines[1])
+	}
+}
+
+func TestAccessLog_LogsRejectedRequests(t *testing.T) {
+	logs := captureLogs(t)
+	r := chi.NewRouter()
+	r.Use(AccessLog(1, nil))
+	r.Use(Authentication(nil, nil))
+	r.Get("/product", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/product", nil))
+	if !strings.Contains(logs.String(), `"status":401`) || !strings.Contains(logs.String(), `"client":"-"`) {
+		t.Errorf("expected the 401 to be logged, got %q", logs.String())
+	}
+}
+
+func TestAccessLog_LogsPanics(t *testing.T) {
+	logs := captureLogs(t)
+	var common bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(Recovery)
+	r.Use(AccessLog(1, &common))
+	r.Get("/product", func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/product", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected Recovery to answer 500, got %d", w.Code)
+	}
+	if !strings.Contains(logs.String(), `"status":500`) {
+		t.Errorf("expected the panicked request to be logged, got %q", logs.String())
+	}
+	if !strings.Contains(common.String(), `"GET /product HTTP/1.1" 500`) {
+		t.Errorf("expected a common log line for the panicked request, got %q", common.String())
+	}
+}
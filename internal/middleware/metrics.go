@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+)
+
+// unmatchedRoute labels requests no route matched, so unknown paths do not each get
+// their own series.
+const unmatchedRoute = "unmatched"
+
+// Metrics counts requests and observes their latency by route pattern and status.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		labels := []string{r.Method, servedRoute(r), strconv.Itoa(statusOf(ww))}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// servedRoute is the chi pattern of the route that served r, once r has been served.
+func servedRoute(r *http.Request) string {
+	// a pattern ending in /* is the mount point of a router none of whose routes matched
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" && !strings.HasSuffix(rctx.RoutePattern(), "/*") {
+		return rctx.RoutePattern()
+	}
+
+	return unmatchedRoute
+}
+
+// statusOf is the status code written to ww, handlers that write nothing respond 200.
+func statusOf(ww chimiddleware.WrapResponseWriter) int {
+	if ww.Status() == 0 {
+		return http.StatusOK
+	}
+
+	return ww.Status()
+}
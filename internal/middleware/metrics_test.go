@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Metrics)
+	r.Get("/livez", func(w http.ResponseWriter, _ *http.Request) {})
+
+	api := chi.NewRouter()
+	api.Get("/order/{orderID}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Mount("/", api)
+
+	requests := func(method, route, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(method, route, status))
+	}
+	before := []float64{
+		requests(http.MethodGet, "/order/{orderID}", "404"),
+		requests(http.MethodGet, "/livez", "200"),
+		requests(http.MethodGet, unmatchedRoute, "404"),
+	}
+
+	for _, path := range []string{"/order/1", "/order/2", "/livez", "/wp-login.php"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := requests(http.MethodGet, "/order/{orderID}", "404") - before[0]; got != 2 {
+		t.Errorf("expected 2 requests counted by route pattern, got %v", got)
+	}
+	if got := requests(http.MethodGet, "/livez", "200") - before[1]; got != 1 {
+		t.Errorf("expected the implicit 200 to be counted, got %v", got)
+	}
+	if got := requests(http.MethodGet, unmatchedRoute, "404") - before[2]; got != 1 {
+		t.Errorf("expected unknown paths to share a series, got %v", got)
+	}
+	if n := testutil.CollectAndCount(metrics.HTTPRequestDuration, "kart_http_request_duration_seconds"); n < 2 {
+		t.Errorf("expected latency series per route, got %d", n)
+	}
+}
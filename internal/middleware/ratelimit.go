@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/ratelimit"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimitStore keeps the token buckets of the clients. ratelimit.MemoryStore implements
+// it, a shared store lets several instances of the service limit together.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy ratelimit.Policy) (ratelimit.Result, error)
+}
+
+// RateLimit rejects clients that used up the tokens of the route policy with 429. The
+// policy is picked by the chi pattern the request matches in routes. Clients are told
+// their quota in RateLimit-* headers. When the store fails requests are let through.
+func RateLimit(store RateLimitStore, routes chi.Routes, policies ratelimit.Policies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			name, policy := policies.For(r.Method, routePattern(routes, r))
+			if policy.Unlimited() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := store.Take(ctx, name+"|"+rateLimitKey(r), policy)
+			if err != nil {
+				log.WithCtx(ctx).Error().Msgf("Error checking rate limit: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(res.Limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+			w.Header().Set(RateLimitResetHeader, seconds(res.Reset))
+			w.Header().Set(RateLimitPolicyHeader, fmt.Sprintf("%d;w=%s;burst=%d", policy.Limit, seconds(policy.Period), res.Limit))
+			if !res.Allowed {
+				log.WithCtx(ctx).Info().Msgf("Rate limit of %s exceeded by %s", name, rateLimitKey(r))
+				w.Header().Set(RetryAfterHeader, seconds(res.RetryAfter))
+				response.Error(w, http.StatusTooManyRequests, "RateLimitError", "rate limit exceeded, retry later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routePattern is the chi pattern of the route the request will be served by, empty for
+// unknown routes.
+func routePattern(routes chi.Routes, r *http.Request) string {
+	return routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+}
+
+// rateLimitKey identifies the client, authenticated clients by their principal and
+// others by IP. Before authentication every request is keyed by IP.
+func rateLimitKey(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return "client:" + principal.ID
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// seconds formats the duration in whole seconds, rounded up so clients do not retry early.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/ratelimit"
+)
+
+// failingStore implements RateLimitStore for testing
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Policy) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store down")
+}
+
+func newRateLimitedRouter(store RateLimitStore) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := r.Header.Get("client"); id != "" {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: id}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.Use(RateLimit(store, r, ratelimit.Policies{
+		Default: ratelimit.Policy{Limit: 100, Period: time.Minute},
+		Routes: map[string]ratelimit.Policy{
+			"POST /order":            {Limit: 1, Period: time.Minute},
+			"GET /order/{orderID}":   {Limit: 2, Period: time.Minute},
+			"PATCH /order/{orderID}": {},
+		},
+	}))
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	r.Post("/order", ok)
+	r.Get("/order", ok)
+	r.Get("/order/{orderID}", ok)
+	r.Patch("/order/{orderID}", ok)
+	return r
+}
+
+func TestRateLimit(t *testing.T) {
+	r := newRateLimitedRouter(ratelimit.NewMemoryStore())
+	send := func(method, path, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if client != "" {
+			req.Header.Set("client", client)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/order", "key-1")
+	if w.Code != http.StatusOK || w.Header().Get(RateLimitLimitHeader) != "1" || w.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Fatalf("expected first order to pass, got %d %v", w.Code, w.Header())
+	}
+	if policy := w.Header().Get(RateLimitPolicyHeader); policy != "1;w=60;burst=1" {
+		t.Errorf("unexpected policy header %s", policy)
+	}
+
+	w = send(http.MethodPost, "/order", "key-1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get(RetryAfterHeader) != "60" {
+		t.Errorf("expected 429 with Retry-After 60, got %d %v", w.Code, w.Header())
+	}
+
+	if w := send(http.MethodPost, "/order", "key-2"); w.Code != http.StatusOK {
+		t.Errorf("expected other clients to be limited on their own, got %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/order", "key-1"); w.Code != http.StatusOK || w.Header().Get(RateLimitLimitHeader) != "100" {
+		t.Errorf("expected the default policy, got %d %v", w.Code, w.Header())
+	}
+
+	// path parameters share the bucket of the route
+	send(http.MethodGet, "/order/1", "")
+	send(http.MethodGet, "/order/2", "")
+	if w := send(http.MethodGet, "/order/3", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the IP to be limited, got %d", w.Code)
+	}
+
+	for range 3 {
+		if w := send(http.MethodPatch, "/order/1", "key-1"); w.Code != http.StatusOK || w.Header().Get(RateLimitLimitHeader) != "" {
+			t.Errorf("expected an unlimited route, got %d %v", w.Code, w.Header())
+		}
+	}
+}
+
+func TestRateLimit_StoreError(t *testing.T) {
+	r := newRateLimitedRouter(failingStore{})
+	for range 2 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/order", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("expected requests to pass when the store fails, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimit_BeforeAuthentication(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(RateLimit(ratelimit.NewMemoryStore(), r, ratelimit.Policies{
+		Default: ratelimit.Policy{Limit: 2, Period: time.Minute},
+	}))
+	r.Use(Authentication(nil, nil))
+	r.Get("/order", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order", nil))
+		if w.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i+1, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+)
+
+// Recovery turns a panic in a handler into a 500 error response. The panic is logged
+// with its stack and counted by route pattern. When the handler already started the
+// response only the log and the metric are written, the client sees a truncated body.
+func Recovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec) // the handler chose to abort the response, let net/http handle it
+			}
+
+			route := servedRoute(r)
+			metrics.Panics.WithLabelValues(r.Method, route).Inc()
+			log.WithCtx(r.Context()).Error().
+				Str("method", r.Method).
+				Str("route", route).
+				Bytes("stack", debug.Stack()).
+				Msgf("panic serving %s %s: %v", r.Method, r.URL.Path, rec)
+
+			if ww.Status() == 0 {
+				response.Error(ww, http.StatusInternalServerError, "Internal server error", "An unexpected error occurred")
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
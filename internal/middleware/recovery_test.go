@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/malakagl/kart-challenge/pkg/constants"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecovery(t *testing.T) {
+	logs := captureLogs(t)
+
+	r := chi.NewRouter()
+	r.Use(TraceMiddleware, Recovery)
+	r.Get("/product/{productID}", func(w http.ResponseWriter, r *http.Request) {
+		// a non-string trace ID used to crash log.WithCtx
+		ctx := context.WithValue(r.Context(), constants.TraceIDKey, 42)
+		log.WithCtx(ctx).Info().Msg("reached")
+		panic("boom")
+	})
+	r.Get("/product", func(w http.ResponseWriter, _ *http.Request) {
+		response.Success(w, []string{})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	panics := func() float64 {
+		return testutil.ToFloat64(metrics.Panics.WithLabelValues(http.MethodGet, "/product/{productID}"))
+	}
+	before := panics()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/product/1", nil)
+	req.Header.Set("X-Request-ID", "trace-1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected a response, got %v", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", res.StatusCode)
+	}
+	var body response.APIResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("expected an error envelope: %v", err)
+	}
+	if body.Code != http.StatusInternalServerError || body.Type != "Internal server error" {
+		t.Errorf("unexpected error envelope %+v", body)
+	}
+	if got := panics() - before; got != 1 {
+		t.Errorf("expected one panic counted, got %v", got)
+	}
+	if !strings.Contains(logs.String(), `"traceId":"trace-1"`) || !strings.Contains(logs.String(), `"stack":`) ||
+		!strings.Contains(logs.String(), "panic serving GET /product/1: boom") {
+		t.Errorf("expected the panic to be logged with trace ID and stack, got %q", logs.String())
+	}
+
+	res2, err := http.Get(srv.URL + "/product")
+	if err != nil {
+		t.Fatalf("expected the server to keep serving, got %v", err)
+	}
+	_ = res2.Body.Close()
+	if res2.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 after a panic, got %d", res2.StatusCode)
+	}
+}
+
+func TestRecovery_AfterWriteHeader(t *testing.T) {
+	captureLogs(t)
+
+	r := chi.NewRouter()
+	r.Use(Recovery)
+	r.Get("/order", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order", nil))
+	if rec.Code != http.StatusAccepted || rec.Body.Len() != 0 {
+		t.Errorf("expected the started response to be left alone, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecovery_AbortHandler(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Recovery)
+	r.Get("/order", func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked, got %v", rec)
+		}
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/order", nil))
+}
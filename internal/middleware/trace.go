@@ -6,16 +6,25 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/malakagl/kart-challenge/pkg/constants"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// TraceMiddleware ensures every request has a trace ID.
+// TraceMiddleware ensures every request has a trace ID. Without an X-Request-ID header
+// the OpenTelemetry trace ID is used when there is one, so logs and spans share an ID.
 func TraceMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
 		traceID := r.Header.Get("X-Request-ID")
 		if traceID == "" {
-			traceID = uuid.New().String()
+			if sc := span.SpanContext(); sc.HasTraceID() {
+				traceID = sc.TraceID().String()
+			} else {
+				traceID = uuid.New().String()
+			}
 		}
 
+		span.SetAttributes(attribute.String("request.id", traceID))
 		w.Header().Set("X-Request-ID", traceID)
 		ctx := context.WithValue(r.Context(), constants.TraceIDKey, traceID)
 		next.ServeHTTP(w, r.WithContext(ctx))
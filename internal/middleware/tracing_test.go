@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider recording every span for the duration of t.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func TestTracing(t *testing.T) {
+	recorder := recordSpans(t)
+
+	r := chi.NewRouter()
+	r.Use(Tracing, TraceMiddleware)
+	r.Get("/order/{orderID}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /order/{orderID}" {
+		t.Errorf("expected the span to be named after the route, got %q", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the incoming trace to be continued, got trace %s", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("expected the incoming span as parent, got %s", got)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("expected an error status for a 500 response, got %v", span.Status().Code)
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the trace ID as request ID, got %q", got)
+	}
+}
+
+func TestTracing_KeepsRequestID(t *testing.T) {
+	recorder := recordSpans(t)
+
+	r := chi.NewRouter()
+	r.Use(Tracing, TraceMiddleware)
+	r.Get("/livez", func(w http.ResponseWriter, _ *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("expected the incoming request ID to be kept, got %q", got)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	found := false
+	for _, attr := range spans[0].Attributes() {
+		found = found || (attr.Key == "request.id" && attr.Value.AsString() == "req-1")
+	}
+	if !found {
+		t.Error("expected the request ID as span attribute")
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Error("expected no error status for a 200 response")
+	}
+}
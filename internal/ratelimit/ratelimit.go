@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/malakagl/kart-challenge/internal/config"
+)
+
+// sweepEvery is how many requests the memory store serves between removing idle buckets.
+const sweepEvery = 1024
+
+// Policy is a token bucket: Limit requests per Period, with bursts of up to Burst
+// requests. Burst defaults to Limit.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// Unlimited tells whether the policy lets every request through.
+func (p Policy) Unlimited() bool {
+	return p.Limit <= 0 || p.Period <= 0
+}
+
+func (p Policy) capacity() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+
+	return float64(p.Limit)
+}
+
+// refill is how long the bucket takes to gain n tokens.
+func (p Policy) refill(n float64) time.Duration {
+	return time.Duration(math.Ceil(n * float64(p.Period) / float64(p.Limit)))
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int           // size of the bucket
+	Remaining  int           // tokens left after the request
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, zero when the request was allowed
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket is full again and can be forgotten
+}
+
+// MemoryStore keeps the buckets in process memory, every instance of the service limits
+// on its own. It implements middleware.RateLimitStore.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take removes a token from the bucket of the key, the request is allowed when there was one.
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	capacity := policy.capacity()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated)
+	if elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed.Seconds()*float64(policy.Limit)/policy.Period.Seconds())
+		b.updated = now
+	}
+
+	res := Result{Limit: int(capacity)}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = policy.refill(1 - b.tokens)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = policy.refill(capacity - b.tokens)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep forgets the buckets that refilled completely, they start out full anyway.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Policies are the policies of the routes, keyed by method and chi route pattern.
+type Policies struct {
+	Default Policy
+	Routes  map[string]Policy
+}
+
+// FromConfig converts the rate limit config.
+func FromConfig(cfg config.RateLimitConfig) Policies {
+	p := Policies{Default: policyOf(cfg.Default), Routes: make(map[string]Policy, len(cfg.Routes))}
+	for route, policy := range cfg.Routes {
+		p.Routes[route] = policyOf(policy)
+	}
+
+	return p
+}
+
+// IPFromConfig converts the per IP policy of the rate limit config, it applies to every route.
+func IPFromConfig(cfg config.RateLimitConfig) Policies {
+	return Policies{Default: policyOf(cfg.IP)}
+}
+
+func policyOf(p config.RateLimitPolicy) Policy {
+	return Policy{Limit: p.Limit, Period: p.Period, Burst: p.Burst}
+}
+
+// For returns the policy of the route and the name its buckets are kept under. Routes
+// without their own policy share the buckets of the default policy.
+func (p Policies) For(method, pattern string) (string, Policy) {
+	route := method + " " + pattern
+	if policy, ok := p.Routes[route]; ok {
+		return route, policy
+	}
+
+	return "default", p.Default
+}
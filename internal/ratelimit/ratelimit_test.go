@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/malakagl/kart-challenge/internal/config"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	policy := Policy{Limit: 60, Period: time.Minute, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, err := s.Take(t.Context(), "client", policy)
+		if err != nil || !res.Allowed || res.Remaining != i || res.Limit != 3 {
+			t.Fatalf("expected request to be allowed with %d remaining, got %+v %v", i, res, err)
+		}
+	}
+
+	res, _ := s.Take(t.Context(), "client", policy)
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("expected request to be rejected for a second, got %+v", res)
+	}
+
+	if res, _ := s.Take(t.Context(), "other", policy); !res.Allowed {
+		t.Errorf("expected other clients to have their own bucket, got %+v", res)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if res, _ := s.Take(t.Context(), "client", policy); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected a refilled token, got %+v", res)
+	}
+
+	// the bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	if res, _ := s.Take(t.Context(), "client", policy); res.Remaining != 2 {
+		t.Errorf("expected 2 remaining, got %+v", res)
+	}
+}
+
+func TestMemoryStore_BurstDefaultsToLimit(t *testing.T) {
+	s := NewMemoryStore()
+	policy := Policy{Limit: 2, Period: time.Hour}
+
+	for range 2 {
+		if res, _ := s.Take(t.Context(), "client", policy); !res.Allowed {
+			t.Fatalf("expected request to be allowed, got %+v", res)
+		}
+	}
+	if res, _ := s.Take(t.Context(), "client", policy); res.Allowed || res.RetryAfter < 29*time.Minute {
+		t.Errorf("expected request to be rejected for half an hour, got %+v", res)
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	policy := Policy{Limit: 1, Period: time.Second}
+
+	_, _ = s.Take(t.Context(), "idle", policy)
+	now = now.Add(time.Minute)
+	for range sweepEvery - 1 {
+		_, _ = s.Take(t.Context(), "busy", policy)
+	}
+
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("expected the refilled bucket to be removed")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("expected the empty bucket to be kept")
+	}
+}
+
+func TestPolicies_For(t *testing.T) {
+	p := FromConfig(config.RateLimitConfig{
+		Default: config.RateLimitPolicy{Limit: 100, Period: time.Minute},
+		Routes: map[string]config.RateLimitPolicy{
+			"POST /order": {Limit: 5, Period: time.Minute, Burst: 2},
+		},
+	})
+
+	if name, policy := p.For("POST", "/order"); name != "POST /order" || policy.Limit != 5 || policy.Burst != 2 {
+		t.Errorf("expected the order policy, got %s %+v", name, policy)
+	}
+	if name, policy := p.For("GET", "/order"); name != "default" || policy.Limit != 100 {
+		t.Errorf("expected the default policy, got %s %+v", name, policy)
+	}
+	if !(Policy{}).Unlimited() {
+		t.Error("expected the zero policy to be unlimited")
+	}
+}
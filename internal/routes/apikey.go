@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/api/handlers"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/middleware"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+	"github.com/malakagl/kart-challenge/pkg/services"
+	"gorm.io/gorm"
+)
+
+func AddAPIKeyRoutes(r *chi.Mux, db *gorm.DB, keyCache services.KeyCache) {
+	uow := repositories.NewUnitOfWork(db)
+	apiKeyRepo := repositories.NewAPIKeyRepo(db)
+	apiKeyService := services.NewAPIKeyService(uow, apiKeyRepo, keyCache)
+	apiKeyHandler := handlers.NewAPIKeyHandler(&apiKeyService)
+
+	r.Route("/admin/api-keys", func(r chi.Router) {
+		r.Use(middleware.RequireScope(auth.ScopeAdmin))
+		r.Post("/", apiKeyHandler.CreateAPIKey)
+		r.Get("/", apiKeyHandler.ListAPIKeys)
+		r.Post("/{apiKeyID}/rotate", apiKeyHandler.RotateAPIKey)
+		r.Delete("/{apiKeyID}", apiKeyHandler.RevokeAPIKey)
+	})
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/api/handlers"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/middleware"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+	"github.com/malakagl/kart-challenge/pkg/services"
+	"gorm.io/gorm"
+)
+
+// AddCategoryRoutes adds the category list and creation. The products of a category are listed by the
+// product routes.
+func AddCategoryRoutes(r *chi.Mux, db *gorm.DB) {
+	categoryService := services.NewCategoryService(repositories.NewCategoryRepo(db))
+	categoryHandler := handlers.NewCategoryHandler(&categoryService)
+	r.With(middleware.RequireScope(auth.ScopeReadProducts)).Get("/category", categoryHandler.ListCategories)
+	r.With(middleware.RequireScope(auth.ScopeAdmin)).Post("/category", categoryHandler.CreateCategory)
+}
@@ -1,14 +1,24 @@
 package routes
 
 import (
-	"net/http"
-
 	"github.com/go-chi/chi/v5"
-	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/internal/api/handlers"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/health"
+	"github.com/malakagl/kart-challenge/internal/middleware"
 )
 
-func AddHealthCheckRoutes(r *chi.Mux) {
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		response.Success(w, "ok")
-	})
+// AddHealthCheckRoutes adds the unauthenticated probes. /health is kept for existing
+// callers and answers like /livez.
+func AddHealthCheckRoutes(r *chi.Mux, liveness, readiness *health.Registry) {
+	healthHandler := handlers.NewHealthHandler(liveness, readiness)
+	r.Get("/health", healthHandler.Livez)
+	r.Get("/livez", healthHandler.Livez)
+	r.Get("/readyz", healthHandler.Readyz)
+}
+
+// AddHealthAdminRoutes adds the detailed report of every check for admins.
+func AddHealthAdminRoutes(r *chi.Mux, liveness, readiness *health.Registry) {
+	healthHandler := handlers.NewHealthHandler(liveness, readiness)
+	r.With(middleware.RequireScope(auth.ScopeAdmin)).Get("/admin/health", healthHandler.Details)
 }
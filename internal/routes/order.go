@@ -3,17 +3,26 @@ package routes
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/malakagl/kart-challenge/internal/api/handlers"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/internal/couponcode"
+	"github.com/malakagl/kart-challenge/internal/middleware"
 	"github.com/malakagl/kart-challenge/pkg/repositories"
 	"github.com/malakagl/kart-challenge/pkg/services"
 	"gorm.io/gorm"
 )
 
-func AddOrderRoutes(r *chi.Mux, db *gorm.DB) {
-	productRepo := repositories.NewProductRepo(db)
+func AddOrderRoutes(r *chi.Mux, db *gorm.DB, couponValidator couponcode.CouponValidator, cfg config.IdempotencyConfig) {
+	uow := repositories.NewUnitOfWork(db)
 	orderRepo := repositories.NewOrderRepo(db)
-	couponCodeRepo := repositories.NewCouponCodeRepository(db)
-	orderService := services.NewOrderService(orderRepo, couponCodeRepo, productRepo)
+	productRepo := repositories.NewProductRepo(db)
+	orderService := services.NewOrderService(uow, couponValidator, orderRepo, productRepo)
 	orderHandler := handlers.NewOrderHandler(&orderService)
 
-	r.Post("/order", orderHandler.CreateOrder)
+	idempotencyRepo := repositories.NewIdempotencyRepo(db)
+	r.With(middleware.RequireScope(auth.ScopeCreateOrder), middleware.Idempotency(&idempotencyRepo, cfg.TTL)).
+		Post("/order", orderHandler.CreateOrder)
+	r.With(middleware.RequireScope(auth.ScopeReadOrders)).Get("/order", orderHandler.ListOrders)
+	r.With(middleware.RequireScope(auth.ScopeReadOrders)).Get("/order/{orderID}", orderHandler.GetOrderByID)
+	r.With(middleware.RequireScope(auth.ScopeUpdateOrders)).Patch("/order/{orderID}/status", orderHandler.UpdateOrderStatus)
 }
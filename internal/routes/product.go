@@ -3,15 +3,27 @@ package routes
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/malakagl/kart-challenge/internal/api/handlers"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/middleware"
 	"github.com/malakagl/kart-challenge/pkg/repositories"
 	"github.com/malakagl/kart-challenge/pkg/services"
 	"gorm.io/gorm"
 )
 
-func AddProductRoutes(r *chi.Mux, db *gorm.DB) {
+func AddProductRoutes(r *chi.Mux, db *gorm.DB, productCache services.ProductCache) {
+	uow := repositories.NewUnitOfWork(db)
 	productRepo := repositories.NewProductRepo(db)
-	productService := services.NewProductService(productRepo)
+	categoryRepo := repositories.NewCategoryRepo(db)
+	productService := services.NewProductService(uow, productRepo, categoryRepo, productCache)
 	productHandler := handlers.NewProductHandler(&productService)
-	r.Get("/product", productHandler.ListProducts)
-	r.Get("/product/{productID}", productHandler.GetProductByID)
+	r.With(middleware.RequireScope(auth.ScopeReadProducts)).Get("/product", productHandler.ListProducts)
+	r.With(middleware.RequireScope(auth.ScopeReadProducts)).Get("/product/search", productHandler.SearchProducts)
+	r.With(middleware.RequireScope(auth.ScopeReadProducts)).Get("/product/{productID}", productHandler.GetProductByID)
+	r.With(middleware.RequireScope(auth.ScopeReadProducts)).Get("/category/{slug}/product", productHandler.ListCategoryProducts)
+
+	admin := r.With(middleware.RequireScope(auth.ScopeAdmin))
+	admin.Post("/product", productHandler.CreateProduct)
+	admin.Put("/product/{productID}", productHandler.ReplaceProduct)
+	admin.Patch("/product/{productID}", productHandler.UpdateProduct)
+	admin.Delete("/product/{productID}", productHandler.DeleteProduct)
 }
@@ -2,38 +2,316 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/cache"
 	"github.com/malakagl/kart-challenge/internal/config"
 	"github.com/malakagl/kart-challenge/internal/couponcode"
 	"github.com/malakagl/kart-challenge/internal/database"
+	"github.com/malakagl/kart-challenge/internal/health"
+	"github.com/malakagl/kart-challenge/internal/metrics"
 	"github.com/malakagl/kart-challenge/internal/middleware"
+	"github.com/malakagl/kart-challenge/internal/ratelimit"
 	"github.com/malakagl/kart-challenge/internal/routes"
+	"github.com/malakagl/kart-challenge/internal/tracing"
 	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+	"github.com/malakagl/kart-challenge/pkg/services"
+	"gorm.io/gorm"
 )
 
+const serviceName = "kart-challenge"
+
+// Start serves the API until SIGINT or SIGTERM, then shuts down gracefully.
 func Start(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// requests and the coupon workers they start run in this context, it is cancelled
+	// when in-flight requests do not finish within the shutdown timeout
+	baseCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdownTracing, err := tracing.Init(ctx, serviceName, cfg.Tracing)
+	if err != nil {
+		log.Error().Msgf("failed to set up tracing: %v", err)
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Msgf("failed to flush traces: %v", err)
+		}
+	}()
+
 	if err := database.RunMigrations(cfg.Database); err != nil {
 		log.Error().Msgf("database migrations failed: %v", err)
 		return err
 	}
 
-	couponcode.SetCouponCodeFiles(cfg.CouponCode.FilePaths)
-	db, err := database.Connect(context.Background(), &cfg.Database)
+	db, err := database.Connect(ctx, &cfg.Database)
 	if err != nil {
 		log.Error().Msgf("failed to connect to database: %v", err)
 		return err
 	}
+	defer func() {
+		if err := database.Close(); err != nil {
+			log.Error().Msgf("failed to close database: %v", err)
+		}
+	}()
+
+	if cfg.Tracing.Enabled {
+		if err := db.Use(database.TracingPlugin{}); err != nil {
+			log.Error().Msgf("failed to trace database queries: %v", err)
+			return err
+		}
+	}
+
+	if cfg.Metrics.Enabled {
+		if err := database.Instrument(db, cfg.Database.Name); err != nil {
+			log.Error().Msgf("failed to instrument database: %v", err)
+			return err
+		}
+	}
+
+	couponCodeRepo := repositories.NewCouponCodeRepository(db)
+	couponValidator, err := couponcode.NewValidator(ctx, cfg.CouponCode, &couponCodeRepo)
+	if err != nil {
+		log.Error().Msgf("failed to set up coupon code validator: %v", err)
+		return err
+	}
+
+	apiKeyRepo := repositories.NewAPIKeyRepo(db)
+	authenticator := auth.NewAPIKeyAuthenticator(&apiKeyRepo, cfg.Auth.CacheTTL)
+	apiKeys, bearer, err := authenticators(cfg.Auth, authenticator)
+	if err != nil {
+		log.Error().Msgf("failed to set up jwt authentication: %v", err)
+		return err
+	}
+
+	liveness, readiness := health.NewRegistry(cfg.Health.CacheTTL), health.NewRegistry(cfg.Health.CacheTTL)
+	if err := registerReadinessChecks(readiness, cfg, db, couponValidator); err != nil {
+		log.Error().Msgf("failed to set up readiness checks: %v", err)
+		return err
+	}
+
+	commonLog, closeCommonLog, err := commonLogWriter(cfg.Logging.Access)
+	if err != nil {
+		log.Error().Msgf("failed to open access log: %v", err)
+		return err
+	}
+	defer func() { _ = closeCommonLog() }()
+
+	var productCache services.ProductCache
+	if cfg.ProductCache.Enabled {
+		productCache = cache.NewLRU[string, any](cfg.ProductCache.Size, cfg.ProductCache.TTL)
+	}
 
 	r := chi.NewRouter()
-	r.Use(middleware.TraceMiddleware, middleware.AuthenticationMiddleware, middleware.LoggingMiddleware)
-	routes.AddHealthCheckRoutes(r)
-	routes.AddProductRoutes(r, db)
-	routes.AddOrderRoutes(r, db)
-
-	serverURL := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	log.Info().Msgf("Server starting on %s", serverURL)
-	return http.ListenAndServe(serverURL, r)
+	r.Use(middleware.Tracing, middleware.TraceMiddleware)
+	if cfg.Metrics.Enabled {
+		r.Use(middleware.Metrics)
+	}
+	r.Use(middleware.Recovery)
+	routes.AddHealthCheckRoutes(r, liveness, readiness)
+
+	api := chi.NewRouter()
+	api.Use(middleware.AccessLog(cfg.Logging.Access.SampleRate, commonLog))
+	if cfg.RateLimit.Enabled {
+		// checked before the credentials, nothing limits guessing them otherwise
+		api.Use(middleware.RateLimit(ratelimit.NewMemoryStore(), api, ratelimit.IPFromConfig(cfg.RateLimit)))
+	}
+	api.Use(middleware.Authentication(apiKeys, bearer))
+	if cfg.RateLimit.Enabled {
+		api.Use(middleware.RateLimit(ratelimit.NewMemoryStore(), api, ratelimit.FromConfig(cfg.RateLimit)))
+	}
+	routes.AddProductRoutes(api, db, productCache)
+	routes.AddCategoryRoutes(api, db)
+	routes.AddOrderRoutes(api, db, couponValidator, cfg.Idempotency)
+	routes.AddAPIKeyRoutes(api, db, authenticator)
+	routes.AddHealthAdminRoutes(api, liveness, readiness)
+	r.Mount("/", api)
+
+	idempotencyRepo := repositories.NewIdempotencyRepo(db)
+	go purgeIdempotencyKeys(baseCtx, &idempotencyRepo, cfg.Idempotency.PurgeInterval)
+
+	srv := newHTTPServer(baseCtx, cfg.Server, r)
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Error().Msgf("failed to listen on %s: %v", srv.Addr, err)
+		return err
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		log.Info().Msgf("Server starting on %s", srv.Addr)
+		errCh <- srv.Serve(ln)
+	}()
+
+	if cfg.Metrics.Enabled {
+		metricsSrv := newMetricsServer(cfg.Server, cfg.Metrics)
+		defer func() { _ = metricsSrv.Close() }()
+		go func() {
+			log.Info().Msgf("Metrics server starting on %s", metricsSrv.Addr)
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		stop() // a second signal kills the process
+	}
+
+	return shutdown(srv, readiness, cancel, cfg.Server)
+}
+
+// purgeIdempotencyKeys deletes expired idempotency keys every interval until ctx is done.
+// Reserve only replaces an expired key when it is reused, the others would stay forever.
+func purgeIdempotencyKeys(ctx context.Context, repo *repositories.IdempotencyRepo, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := repo.PurgeExpired(ctx); err == nil && n > 0 {
+				log.Info().Msgf("Purged %d expired idempotency keys", n)
+			}
+		}
+	}
+}
+
+func newHTTPServer(baseCtx context.Context, cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+}
+
+// newMetricsServer serves the Prometheus metrics on the metrics port, apart from the API
+// so they need no credentials and are not reachable through the public port.
+func newMetricsServer(server config.ServerConfig, cfg config.MetricsConfig) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, metrics.Handler())
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", server.Host, cfg.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: server.ReadHeaderTimeout,
+	}
+}
+
+// shutdown fails readiness for the drain period so load balancers stop routing to the
+// server, then stops accepting connections and waits for in-flight requests. Requests
+// still running after the shutdown timeout are cancelled.
+func shutdown(srv *http.Server, readiness *health.Registry, cancel context.CancelFunc, cfg config.ServerConfig) error {
+	log.Info().Msgf("Shutdown requested, draining for %s", cfg.DrainPeriod)
+	readiness.Drain()
+	time.Sleep(cfg.DrainPeriod)
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelTimeout()
+	err := srv.Shutdown(ctx)
+	cancel()
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Error().Msgf("In-flight requests did not finish within %s, closing connections", cfg.ShutdownTimeout)
+		err = srv.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("shutdown failed: %w", err)
+	}
+
+	log.Info().Msg("Server stopped")
+	return nil
+}
+
+// registerReadinessChecks adds the checks of the dependencies requests need.
+func registerReadinessChecks(readiness *health.Registry, cfg *config.Config, db *gorm.DB, v couponcode.CouponValidator) error {
+	expected, err := database.LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	timeout := cfg.Health.CheckTimeout
+	readiness.Register("database", timeout, health.Database(db))
+	readiness.Register("migrations", timeout, health.Migrations(database.MigrationVersion(db), expected))
+	readiness.Register("coupon_codes", timeout, func(ctx context.Context) error {
+		return couponcode.Ready(ctx, v)
+	})
+	if dir := couponDir(cfg.CouponCode); dir != "" {
+		readiness.Register("disk_space", timeout, health.DiskSpace(dir, uint64(cfg.Health.MinFreeDiskMB)<<20))
+	}
+
+	return nil
+}
+
+// couponDir is where the coupon index is written and files are unzipped.
+func couponDir(cfg config.CouponCodeConfig) string {
+	switch {
+	case cfg.IndexPath != "":
+		return filepath.Dir(cfg.IndexPath)
+	case len(cfg.FilePaths) > 0:
+		return filepath.Dir(cfg.FilePaths[0])
+	default:
+		return ""
+	}
+}
+
+// commonLogWriter returns where Common Log Format access log lines are written, nil
+// when they are not enabled.
+func commonLogWriter(cfg config.AccessLogConfig) (io.Writer, func() error, error) {
+	noop := func() error { return nil }
+	switch {
+	case !cfg.CommonLog:
+		return nil, noop, nil
+	case cfg.CommonFile == "":
+		return os.Stdout, noop, nil
+	}
+
+	f, err := os.OpenFile(cfg.CommonFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f.Close, nil
+}
+
+// authenticators returns the authenticators of the configured auth mode, nil for the
+// credentials that are not accepted.
+func authenticators(cfg config.AuthConfig, apiKeys *auth.APIKeyAuthenticator) (middleware.Authenticator, middleware.Authenticator, error) {
+	var apiKeyAuth, bearerAuth middleware.Authenticator
+	if cfg.APIKeysEnabled() {
+		apiKeyAuth = apiKeys
+	}
+	if cfg.JWTEnabled() {
+		jwtAuth, err := auth.NewJWTAuthenticator(cfg.JWT)
+		if err != nil {
+			return nil, nil, err
+		}
+		bearerAuth = jwtAuth
+	}
+
+	log.Info().Msgf("Authentication mode %s", cfg.Mode)
+	return apiKeyAuth, bearerAuth, nil
 }
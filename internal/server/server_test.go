@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/malakagl/kart-challenge/internal/config"
+	"github.com/malakagl/kart-challenge/internal/health"
+	"github.com/malakagl/kart-challenge/internal/routes"
+)
+
+func startServer(t *testing.T, cfg config.ServerConfig, handler http.Handler) (*http.Server, string, context.CancelFunc) {
+	t.Helper()
+	baseCtx, cancel := context.WithCancel(context.Background())
+	srv := newHTTPServer(baseCtx, cfg, handler)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+	return srv, "http://" + ln.Addr().String(), cancel
+}
+
+func TestShutdown_DrainsInFlightRequests(t *testing.T) {
+	readiness := health.NewRegistry(0)
+	started := make(chan struct{})
+	r := chi.NewRouter()
+	routes.AddHealthCheckRoutes(r, health.NewRegistry(0), readiness)
+	r.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cfg := config.ServerConfig{DrainPeriod: 100 * time.Millisecond, ShutdownTimeout: 5 * time.Second}
+	srv, url, cancel := startServer(t, cfg, r)
+
+	status := make(chan int, 1)
+	go func() {
+		res, err := http.Get(url + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		_ = res.Body.Close()
+		status <- res.StatusCode
+	}()
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- shutdown(srv, readiness, cancel, cfg) }()
+
+	// readiness fails while the server drains
+	time.Sleep(20 * time.Millisecond)
+	res, err := http.Get(url + "/readyz")
+	if err != nil {
+		t.Fatalf("expected the server to serve during the drain period: %v", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail, got %d", res.StatusCode)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+	if code := <-status; code != http.StatusOK {
+		t.Errorf("expected the in-flight request to finish, got %d", code)
+	}
+	if _, err := http.Get(url + "/health"); err == nil {
+		t.Error("expected the server to stop accepting connections")
+	}
+}
+
+func TestShutdown_CancelsRequestsAfterTimeout(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	r := chi.NewRouter()
+	r.Get("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})
+
+	cfg := config.ServerConfig{DrainPeriod: time.Millisecond, ShutdownTimeout: 50 * time.Millisecond}
+	srv, url, cancel := startServer(t, cfg, r)
+	go func() {
+		if res, err := http.Get(url + "/stuck"); err == nil {
+			_ = res.Body.Close()
+		}
+	}()
+	<-started
+
+	if err := shutdown(srv, health.NewRegistry(0), cancel, cfg); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected the request context to be cancelled")
+	}
+}
+
+func TestNewMetricsServer(t *testing.T) {
+	srv := newMetricsServer(config.ServerConfig{Host: "127.0.0.1"}, config.MetricsConfig{Port: 9090, Path: "/metrics"})
+	if srv.Addr != "127.0.0.1:9090" {
+		t.Errorf("expected the metrics port, got %s", srv.Addr)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected metrics to be served, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/product", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected only metrics on the metrics port, got %d", w.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInit_Disabled(t *testing.T) {
+	shutdown, err := Init(context.Background(), "test", config.TracingConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, span := Start(context.Background(), "ignored")
+	span.End()
+	if span.IsRecording() {
+		t.Error("expected spans not to be recorded with tracing disabled")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestInit_StdoutFile(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Init(context.Background(), "test", config.TracingConfig{
+		Enabled:     true,
+		Exporter:    ExporterStdout,
+		FilePath:    path,
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("boom"))
+	parent.End()
+	if child.(interface{ Parent() trace.SpanContext }).Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the child span to be a child of the span in ctx")
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace file: %v", err)
+	}
+	for _, want := range []string{`"Name":"parent"`, `"Name":"child"`, `"Description":"boom"`, `"Value":"test"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected trace file to contain %s", want)
+		}
+	}
+}
+
+func TestInit_UnknownExporter(t *testing.T) {
+	_, err := Init(context.Background(), "test", config.TracingConfig{Enabled: true, Exporter: "zipkin"})
+	if err == nil {
+		t.Fatal("expected an error for an unknown exporter")
+	}
+}
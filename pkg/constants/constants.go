@@ -2,4 +2,10 @@ package constants
 
 type contextKey string
 
-const TraceIDKey contextKey = "traceID"
+const (
+	TraceIDKey       contextKey = "traceID"
+	ClientIDKey      contextKey = "clientID" // identifies the API client, used for per customer limits
+	PrincipalKey     contextKey = "principal"
+	PrincipalSlotKey contextKey = "principalSlot" // filled on authentication for middleware running before it
+	CustomerIDKey    contextKey = "customerID"    // customer of a bearer token, not set for API keys
+)
@@ -3,9 +3,33 @@ package errors
 import "errors"
 
 var (
-	ErrProductNotFound     = errors.New("product not found")
-	ErrInvalidCouponCode   = errors.New("invalid coupon code")
-	ErrInvalidProductID    = errors.New("invalid product ID")
-	ErrInternalServerError = errors.New("internal server error")
-	ErrDatabaseError       = errors.New("database query returned error")
+	ErrProductNotFound         = errors.New("product not found")
+	ErrInvalidCouponCode       = errors.New("invalid coupon code")
+	ErrInvalidProductID        = errors.New("invalid product ID")
+	ErrInvalidProductPrice     = errors.New("product price must be between 0 and 99999999.99")
+	ErrCategoryNotFound        = errors.New("category not found")
+	ErrCategoryExists          = errors.New("a category with the same slug already exists")
+	ErrInvalidCategoryName     = errors.New("category name must contain a letter or digit")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrInvalidOrderID          = errors.New("invalid order ID")
+	ErrInvalidCursor           = errors.New("invalid pagination cursor")
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
+	ErrInternalServerError     = errors.New("internal server error")
+	ErrDatabaseError           = errors.New("database query returned error")
+	ErrPromotionNotFound       = errors.New("promotion not found")
+	ErrCouponExpired           = errors.New("coupon code has expired")
+	ErrCouponNotYetValid       = errors.New("coupon code is not valid yet")
+	ErrCouponExhausted         = errors.New("coupon code redemption limit reached")
+	ErrCouponBelowMinimum      = errors.New("order total is below the coupon minimum order value")
+	ErrCouponIndexCorrupt      = errors.New("coupon index file is corrupt")
+	ErrCouponIndexStale        = errors.New("coupon index file is out of date")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrInvalidAPIKey           = errors.New("invalid api key")
+	ErrAPIKeyExpired           = errors.New("api key has expired")
+	ErrAPIKeyRevoked           = errors.New("api key has been revoked")
+	ErrAPIKeyRotated           = errors.New("api key has already been rotated")
+	ErrInvalidAPIKeyID         = errors.New("invalid api key ID")
+	ErrInvalidRotationOverlap  = errors.New("invalid rotation overlap")
+	ErrInvalidToken            = errors.New("invalid bearer token")
+	ErrTokenExpired            = errors.New("bearer token has expired")
 )
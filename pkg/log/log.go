@@ -10,6 +10,7 @@ import (
 	"github.com/malakagl/kart-challenge/pkg/constants"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -44,13 +45,24 @@ func Init(serviceName string, cfg config.LoggingConfig) {
 	log.Logger = Logger
 }
 
+// WithCtx returns a logger tagged with the trace ID of the request in ctx and, when the
+// request is traced, the OpenTelemetry trace and span IDs.
 func WithCtx(ctx context.Context) *zerolog.Logger {
-	traceID := ctx.Value(constants.TraceIDKey)
-	if traceID == nil {
+	traceID, _ := ctx.Value(constants.TraceIDKey).(string)
+	sc := trace.SpanContextFromContext(ctx)
+	if traceID == "" && !sc.IsValid() {
 		return &Logger
 	}
 
-	l := Logger.With().Str("traceId", traceID.(string)).Logger()
+	lc := Logger.With()
+	if traceID != "" {
+		lc = lc.Str("traceId", traceID)
+	}
+	if sc.IsValid() {
+		lc = lc.Str("otelTraceId", sc.TraceID().String()).Str("spanId", sc.SpanID().String())
+	}
+
+	l := lc.Logger()
 	return &l
 }
 
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey represents the api_keys table, the key itself is never stored
+type APIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name       string     `gorm:"size:100;not null"`
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex"` // hex SHA-256 of the key
+	Prefix     string     `gorm:"size:12;not null"`             // start of the key to recognise it in listings
+	Scopes     string     `gorm:"size:255;not null"`            // space separated
+	ExpiresAt  *time.Time // nil never expires
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *uuid.UUID `gorm:"type:uuid"` // key issued when this one was rotated
+	CreatedAt  time.Time  `gorm:"autoCreateTime"`
+}
+
+// APIKeyAudit represents the api_key_audit table
+type APIKeyAudit struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement"`
+	APIKeyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	Action    string    `gorm:"size:20;not null"`
+	ActorID   string    `gorm:"size:64;not null"`
+	Details   string    `gorm:"size:255;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName keeps the audit table name singular like the migration
+func (APIKeyAudit) TableName() string {
+	return "api_key_audit"
+}
+
+// ScopeList splits the space separated scopes of the key.
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
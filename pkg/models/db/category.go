@@ -0,0 +1,13 @@
+package db
+
+import "time"
+
+// Category represents the categories table
+type Category struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement"`
+	Name         string    `gorm:"size:255;not null;uniqueIndex"`
+	Slug         string    `gorm:"size:255;not null;uniqueIndex"`
+	DisplayOrder int       `gorm:"not null;default:0"`
+	Active       bool      `gorm:"not null;default:true"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,14 @@
+package db
+
+import "time"
+
+// IdempotencyKey represents the idempotency_keys table
+type IdempotencyKey struct {
+	ClientID     string    `gorm:"size:64;primaryKey"`
+	Key          string    `gorm:"size:255;primaryKey"`
+	RequestHash  string    `gorm:"size:64;not null"`
+	StatusCode   *int      // nil while the request is in progress
+	ResponseBody []byte    `gorm:"type:bytea"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
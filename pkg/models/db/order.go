@@ -4,19 +4,42 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/pkg/money"
 )
 
 type Order struct {
-	ID        uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Total     float64         `gorm:"not null"`
-	Discounts float64         `gorm:"not null"`
-	Products  []*OrderProduct `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
-	CreatedAt time.Time       `gorm:"autoCreateTime"`
+	ID         uuid.UUID             `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Total      money.Money           `gorm:"type:decimal(10,2);not null"`
+	Discounts  money.Money           `gorm:"type:decimal(10,2);not null"`
+	CouponCode string                `gorm:"size:10"`
+	Status     string                `gorm:"size:20;not null;default:pending"`
+	Products   []*OrderProduct       `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	Redemption *CouponRedemption     `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	History    []*OrderStatusHistory `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time             `gorm:"autoCreateTime"`
 }
 
 type OrderProduct struct {
-	ID        int       `gorm:"primaryKey;autoIncrement"`
-	OrderID   uuid.UUID `gorm:"type:uuid;not null;index"`
-	ProductID string    `gorm:"not null" json:"productId" validate:"required"`
-	Quantity  int       `gorm:"not null" json:"quantity" validate:"required,min=1"`
+	ID        int         `gorm:"primaryKey;autoIncrement"`
+	OrderID   uuid.UUID   `gorm:"type:uuid;not null;index"`
+	ProductID string      `gorm:"not null" json:"productId" validate:"required"`
+	Quantity  int         `gorm:"not null" json:"quantity" validate:"required,min=1"`
+	Name      string      `gorm:"size:255;not null"`           // product name when the order was placed
+	UnitPrice money.Money `gorm:"type:decimal(10,2);not null"` // product price when the order was placed
+}
+
+// OrderStatusHistory represents the order_status_history table
+type OrderStatusHistory struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
+	OrderID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FromStatus string    `gorm:"size:20;not null"`
+	ToStatus   string    `gorm:"size:20;not null"`
+	ChangedBy  string    `gorm:"size:64;not null"`
+	Reason     string    `gorm:"size:255;not null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName keeps the history table name singular like the migration
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
 }
@@ -1,15 +1,23 @@
 package db
 
-import "time"
+import (
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+	"gorm.io/gorm"
+)
 
 // Product represents the products table
 type Product struct {
-	ID        uint         `gorm:"primaryKey;autoIncrement"` // use uint for auto-increment
-	Name      string       `gorm:"size:255;not null"`
-	Price     float64      `gorm:"not null"`
-	Category  string       `gorm:"size:255;not null"`
-	Image     ProductImage `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"` // one-to-one relation
-	CreatedAt time.Time    `gorm:"autoCreateTime"`
+	ID         uint           `gorm:"primaryKey;autoIncrement"` // use uint for auto-increment
+	Name       string         `gorm:"size:255;not null"`
+	Price      money.Money    `gorm:"type:decimal(10,2);not null"`
+	Category   string         `gorm:"size:255;not null"` // name of the category, kept for backwards compatibility
+	CategoryID uint           `gorm:"not null;index"`
+	Image      ProductImage   `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"` // one-to-one relation
+	CreatedAt  time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt `gorm:"index"` // soft delete, queries skip deleted products unless Unscoped
 }
 
 // ProductImage represents the product_images table
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
+// Promotion represents the promotions table, a set of discount rules attached to a coupon code
+type Promotion struct {
+	ID          uint            `gorm:"primaryKey;autoIncrement"`
+	CouponCode  string          `gorm:"size:10;not null;uniqueIndex"`
+	Description string          `gorm:"size:255;not null"`
+	Active      bool            `gorm:"not null"`
+	Rules       []PromotionRule `gorm:"foreignKey:PromotionID;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime"`
+
+	// coupon metadata, nil means unrestricted
+	ValidFrom                 *time.Time
+	ValidTo                   *time.Time
+	MaxRedemptions            *int
+	MaxRedemptionsPerCustomer *int
+	MinOrderValue             money.Money `gorm:"type:decimal(10,2);not null"`
+}
+
+// PromotionRule represents the promotion_rules table
+type PromotionRule struct {
+	ID           uint        `gorm:"primaryKey;autoIncrement"`
+	PromotionID  uint        `gorm:"not null;index"`
+	Type         string      `gorm:"size:32;not null"`
+	Percentage   float64     `gorm:"not null"`
+	Amount       money.Money `gorm:"type:decimal(10,2);not null"`
+	ProductID    *uint       `gorm:"default:null"` // product for buy_x_get_y rules
+	BuyQuantity  int         `gorm:"not null"`
+	FreeQuantity int         `gorm:"not null"`
+	MinQuantity  int         `gorm:"not null"`
+	Category     string      `gorm:"size:255;not null"`
+	CreatedAt    time.Time   `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CouponRedemption represents the coupon_redemptions table, one row per order placed with a coupon code
+type CouponRedemption struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
+	CouponCode string    `gorm:"size:10;not null;index:idx_coupon_redemptions_code_customer"`
+	OrderID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	CustomerID string    `gorm:"size:64;not null;index:idx_coupon_redemptions_code_customer"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
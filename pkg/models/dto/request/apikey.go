@@ -0,0 +1,15 @@
+package request
+
+import "time"
+
+// APIKeyRequest is the body of POST /admin/api-keys
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=read_products create_order read_orders update_orders admin"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APIKeyRotateRequest is the optional body of POST /admin/api-keys/{id}/rotate
+type APIKeyRotateRequest struct {
+	Overlap string `json:"overlap,omitempty"` // how long the old key keeps working, e.g. "24h"
+}
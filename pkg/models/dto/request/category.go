@@ -0,0 +1,7 @@
+package request
+
+// CategoryRequest is the body of POST /category
+type CategoryRequest struct {
+	Name         string `json:"name" validate:"required,max=255"`
+	DisplayOrder int    `json:"displayOrder" validate:"min=0"`
+}
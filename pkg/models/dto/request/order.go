@@ -1,5 +1,11 @@
 package request
 
+import (
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
 type OrderRequest struct {
 	CouponCode string `json:"couponCode,omitempty"`
 	Items      []Item `json:"items" validate:"required,dive,required"`
@@ -9,3 +15,20 @@ type Item struct {
 	ProductID string `json:"productId" validate:"required"`
 	Quantity  int    `json:"quantity" validate:"required,min=1"`
 }
+
+// OrderListRequest holds the query parameters of GET /order
+type OrderListRequest struct {
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	CouponCode  string `validate:"omitempty,min=8,max=10"`
+	MinTotal    *money.Money
+	MaxTotal    *money.Money
+	Limit       int `validate:"omitempty,min=1,max=100"`
+	Cursor      string
+}
+
+// OrderStatusRequest is the body of PATCH /order/{orderID}/status
+type OrderStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=pending confirmed preparing ready completed cancelled refunded"`
+	Reason string `json:"reason,omitempty" validate:"max=255"`
+}
@@ -0,0 +1,54 @@
+package request
+
+import "github.com/malakagl/kart-challenge/pkg/money"
+
+// ProductRequest is the body of POST /product and PUT /product/{productID}
+type ProductRequest struct {
+	Name     string              `json:"name" validate:"required,max=255"`
+	Price    *money.Money        `json:"price" validate:"required"`
+	Category string              `json:"category" validate:"required,max=255"`
+	Image    ProductImageRequest `json:"image" validate:"required"`
+}
+
+type ProductImageRequest struct {
+	Thumbnail string `json:"thumbnail" validate:"required,max=255"`
+	Mobile    string `json:"mobile" validate:"required,max=255"`
+	Tablet    string `json:"tablet" validate:"required,max=255"`
+	Desktop   string `json:"desktop" validate:"required,max=255"`
+}
+
+// ProductPatchRequest is the body of PATCH /product/{productID}, fields left out are kept
+type ProductPatchRequest struct {
+	Name     *string                   `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Price    *money.Money              `json:"price,omitempty"`
+	Category *string                   `json:"category,omitempty" validate:"omitempty,min=1,max=255"`
+	Image    *ProductImagePatchRequest `json:"image,omitempty"`
+}
+
+type ProductImagePatchRequest struct {
+	Thumbnail *string `json:"thumbnail,omitempty" validate:"omitempty,min=1,max=255"`
+	Mobile    *string `json:"mobile,omitempty" validate:"omitempty,min=1,max=255"`
+	Tablet    *string `json:"tablet,omitempty" validate:"omitempty,min=1,max=255"`
+	Desktop   *string `json:"desktop,omitempty" validate:"omitempty,min=1,max=255"`
+}
+
+// ProductListRequest holds the query parameters of GET /product. CategorySlug is the
+// path parameter of GET /category/{slug}/product.
+type ProductListRequest struct {
+	Category     string `validate:"omitempty,max=255"`
+	CategorySlug string `validate:"omitempty,max=255"`
+	MinPrice     *money.Money
+	MaxPrice     *money.Money
+	Query        string `validate:"omitempty,max=100"`
+	Sort         string `validate:"omitempty,oneof=price name created_at"`
+	Order        string `validate:"omitempty,oneof=asc desc"`
+	Limit        int    `validate:"omitempty,min=1,max=100"`
+	Cursor       string
+}
+
+// ProductSearchRequest holds the query parameters of GET /product/search
+type ProductSearchRequest struct {
+	Query string `validate:"required,max=100"`
+	Fuzzy bool
+	Limit int `validate:"omitempty,min=1,max=50"`
+}
@@ -0,0 +1,26 @@
+package response
+
+import "time"
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Key        string     `json:"key,omitempty"` // plaintext key, only returned when the key is issued
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	ReplacedBy string     `json:"replacedBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type APIKeysResponse struct {
+	APIKeys []APIKey `json:"apiKeys"`
+}
+
+// APIKeyRotation is the key issued by a rotation and the old key it replaces
+type APIKeyRotation struct {
+	New APIKey `json:"new"`
+	Old APIKey `json:"old"`
+}
@@ -0,0 +1,12 @@
+package response
+
+type Category struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	DisplayOrder int    `json:"displayOrder"`
+}
+
+type CategoriesResponse struct {
+	Categories []Category `json:"categories"`
+}
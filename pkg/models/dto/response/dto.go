@@ -8,10 +8,18 @@ import (
 )
 
 type APIResponse struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Type    string      `json:"type"`
-	Data    interface{} `json:"data"`
+	Code       int         `json:"code"`
+	Message    string      `json:"message"`
+	Type       string      `json:"type"`
+	Data       interface{} `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes a page of a cursor paginated list
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
 }
 
 func JSON(w http.ResponseWriter, code int, resp APIResponse) {
@@ -43,3 +51,13 @@ func Success(w http.ResponseWriter, data interface{}) {
 		Data:    data,
 	})
 }
+
+func SuccessWithPagination(w http.ResponseWriter, data interface{}, p *Pagination) {
+	JSON(w, http.StatusOK, APIResponse{
+		Code:       http.StatusOK,
+		Type:       "Success",
+		Message:    "OK",
+		Data:       data,
+		Pagination: p,
+	})
+}
@@ -1,11 +1,34 @@
 package response
 
+import (
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
 type OrderResponse struct {
-	ID        string    `json:"id"`
-	Total     float64   `json:"total"`
-	Discounts float64   `json:"discounts,omitempty"`
-	Items     []Item    `json:"items"`
-	Products  []Product `json:"products"`
+	ID         string      `json:"id"`
+	Total      money.Money `json:"total"`
+	Discounts  money.Money `json:"discounts,omitzero"`
+	CouponCode string      `json:"couponCode,omitempty"`
+	Status     string      `json:"status"`
+	Items      []Item      `json:"items"`
+	Products   []Product   `json:"products"`
+	CreatedAt  time.Time   `json:"createdAt"`
+
+	StatusHistory []StatusChange `json:"statusHistory,omitempty"`
+}
+
+type StatusChange struct {
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to"`
+	ChangedBy string    `json:"changedBy"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+type OrdersResponse struct {
+	Orders []OrderResponse `json:"orders"`
 }
 
 type Item struct {
@@ -1,11 +1,18 @@
 package response
 
+import (
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
 type Product struct {
-	ID       string       `json:"id"`
-	Name     string       `gorm:"size:255;not null"`
-	Price    float64      `gorm:"not null"`
-	Category string       `gorm:"size:255;not null"`
-	Image    ProductImage `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"` // one-to-one relation
+	ID        string       `json:"id"`
+	Name      string       `gorm:"size:255;not null"`
+	Price     money.Money  `gorm:"not null"`
+	Category  string       `gorm:"size:255;not null"`
+	Image     ProductImage `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE"` // one-to-one relation
+	UpdatedAt time.Time    `json:"-"`                                                // Last-Modified of the product
 }
 
 type ProductImage struct {
@@ -19,6 +26,24 @@ type Products []Product
 
 type ProductsResponse struct {
 	Products
+	LastModified time.Time `json:"-"` // when the catalog last changed
 }
 
 type ProductResponse Product
+
+// ProductSearchResult is a product found by a search, Highlight holds its name and
+// category with the matched words wrapped in <mark> tags.
+type ProductSearchResult struct {
+	Product
+	Rank      float64          `json:"rank"`
+	Highlight ProductHighlight `json:"highlight"`
+}
+
+type ProductHighlight struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type ProductSearchResponse struct {
+	Results []ProductSearchResult `json:"results"`
+}
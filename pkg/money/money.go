@@ -0,0 +1,430 @@
+// Package money represents amounts of money as integer minor units of a currency, so
+// prices and totals can be added and multiplied without floating point rounding errors.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	ErrOverflow         = errors.New("money: amount out of range")
+	ErrInvalidAmount    = errors.New("money: invalid amount")
+	ErrDivisionByZero   = errors.New("money: division by zero")
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	JPY Currency = "JPY"
+)
+
+// DefaultCurrency is the currency of amounts read from the database and JSON, which do
+// not carry a currency code.
+var DefaultCurrency = USD
+
+// Exponent is the number of decimal places of the minor unit of the currency.
+func (c Currency) Exponent() int {
+	switch c {
+	case JPY, "KRW", "VND", "CLP", "ISK":
+		return 0
+	case "BHD", "KWD", "OMR", "JOD", "TND":
+		return 3
+	default:
+		return 2
+	}
+}
+
+// RoundingMode decides how results with more decimal places than the currency has are
+// rounded to minor units.
+type RoundingMode int
+
+const (
+	// HalfUp rounds to the nearest minor unit, halves away from zero.
+	HalfUp RoundingMode = iota
+	// HalfEven rounds to the nearest minor unit, halves to the even neighbour.
+	HalfEven
+	// Down rounds towards zero.
+	Down
+	// Up rounds away from zero.
+	Up
+)
+
+// Money is an amount in minor units of a currency, e.g. cents for USD. The zero value is
+// zero without a currency and takes the currency of the other operand in arithmetic.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+// New returns the amount of minor units in the currency.
+func New(minor int64, c Currency) Money {
+	return Money{amount: minor, currency: c}
+}
+
+// Zero returns no money in the currency.
+func Zero(c Currency) Money {
+	return Money{currency: c}
+}
+
+// Parse reads a decimal amount such as "6.50" or "-3". Amounts with more decimal places
+// than the currency has are rejected, trailing zeros do not count.
+func Parse(s string, c Currency) (Money, error) {
+	str := strings.TrimSpace(s)
+	neg := strings.HasPrefix(str, "-")
+	if neg {
+		str = str[1:]
+	} else {
+		str = strings.TrimPrefix(str, "+")
+	}
+
+	whole, frac, _ := strings.Cut(str, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	exp := c.Exponent()
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, exp)
+	}
+
+	amount, err := strconv.ParseInt(whole+frac+strings.Repeat("0", exp-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	if neg {
+		amount = -amount
+	}
+
+	return Money{amount: amount, currency: c}, nil
+}
+
+// MustParse is like Parse but panics on invalid amounts. It is meant for constants and tests.
+func MustParse(s string, c Currency) Money {
+	m, err := Parse(s, c)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Minor returns the amount in minor units.
+func (m Money) Minor() int64 {
+	return m.amount
+}
+
+// Currency returns the currency of the amount.
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.amount < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.amount > 0
+}
+
+func (m Money) sameCurrency(o Money) (Currency, error) {
+	switch {
+	case m.currency == o.currency || o.currency == "":
+		return m.currency, nil
+	case m.currency == "":
+		return o.currency, nil
+	default:
+		return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) (Money, error) {
+	c, err := m.sameCurrency(o)
+	if err != nil {
+		return Money{}, err
+	}
+
+	sum := m.amount + o.amount
+	if (sum > m.amount) != (o.amount > 0) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{amount: sum, currency: c}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+
+	return m.Add(Money{amount: -o.amount, currency: o.currency})
+}
+
+// Mul returns m * n, e.g. the price of n units.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.amount == 0 || n == 0 {
+		return Money{currency: m.currency}, nil
+	}
+
+	product := m.amount * n
+	if product/n != m.amount || (n == -1 && m.amount == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{amount: product, currency: m.currency}, nil
+}
+
+// MulRat returns m * num / den rounded to minor units with the rounding mode, e.g.
+// MulRat(1250, 10000, HalfUp) is 12.5% of m.
+func (m Money) MulRat(num, den int64, mode RoundingMode) (Money, error) {
+	if den == 0 {
+		return Money{}, ErrDivisionByZero
+	}
+
+	n := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(num))
+	d := big.NewInt(den)
+	if d.Sign() < 0 {
+		n.Neg(n)
+		d.Neg(d)
+	}
+
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() != 0 && roundAway(q, r, d, mode) {
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{amount: q.Int64(), currency: m.currency}, nil
+}
+
+// roundAway tells whether the truncated quotient q with remainder r of a division by d
+// has to move one minor unit away from zero.
+func roundAway(q, r, d *big.Int, mode RoundingMode) bool {
+	switch mode {
+	case Down:
+		return false
+	case Up:
+		return true
+	}
+
+	twice := new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2))
+	switch twice.Cmp(d) {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+
+	// exactly half
+	if mode == HalfEven {
+		return q.Bit(0) == 1
+	}
+
+	return true
+}
+
+// Percent returns percent % of m, rounded to minor units with the rounding mode. The
+// percentage is taken to two decimal places, e.g. 12.5 or 33.33.
+func (m Money) Percent(percent float64, mode RoundingMode) (Money, error) {
+	if math.IsNaN(percent) || math.IsInf(percent, 0) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	return m.MulRat(int64(math.Round(percent*100)), 100*100, mode)
+}
+
+// Cmp compares m and o and returns -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Min returns the smaller of m and o.
+func (m Money) Min(o Money) (Money, error) {
+	c, err := m.sameCurrency(o)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{amount: min(m.amount, o.amount), currency: c}, nil
+}
+
+// Decimal formats the amount with all decimal places of the currency, e.g. "6.50".
+func (m Money) Decimal() string {
+	exp := m.currency.Exponent()
+	if m.currency == "" {
+		exp = DefaultCurrency.Exponent()
+	}
+
+	abs := strconv.FormatUint(absUint(m.amount), 10)
+	if len(abs) <= exp {
+		abs = strings.Repeat("0", exp-len(abs)+1) + abs
+	}
+
+	s := abs
+	if exp > 0 {
+		s = abs[:len(abs)-exp] + "." + abs[len(abs)-exp:]
+	}
+	if m.amount < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
+
+// Float64 approximates the amount in the major unit, e.g. 6.5. It is meant for
+// reporting such as metrics, never compute amounts with it.
+func (m Money) Float64() float64 {
+	f, _ := strconv.ParseFloat(m.Decimal(), 64)
+	return f
+}
+
+func absUint(v int64) uint64 {
+	if v < 0 {
+		return uint64(-(v + 1)) + 1
+	}
+
+	return uint64(v)
+}
+
+// String formats the amount with its currency, e.g. "6.50 USD".
+func (m Money) String() string {
+	if m.currency == "" {
+		return m.Decimal()
+	}
+
+	return m.Decimal() + " " + string(m.currency)
+}
+
+// MarshalJSON writes the amount as a JSON number without trailing zeros, e.g. 6.5 or
+// 100, the same way float64 amounts were written.
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.Decimal()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+
+	return []byte(s), nil
+}
+
+// UnmarshalJSON reads a JSON number or numeric string in DefaultCurrency. Like Parse it
+// rejects more decimal places than the currency has, rounding would turn e.g. -0.001
+// into a valid 0.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		s = str
+	}
+
+	parsed, err := Parse(s, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// parseScanned reads a database decimal in DefaultCurrency, accepting exponents and more
+// decimal places than the currency has, which are rounded half up.
+func parseScanned(s string) (Money, error) {
+	if m, err := Parse(s, DefaultCurrency); err == nil {
+		return m, nil
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(DefaultCurrency.Exponent())), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 && roundAway(q, rem, r.Denom(), HalfUp) {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+
+	return Money{amount: q.Int64(), currency: DefaultCurrency}, nil
+}
+
+// Value stores the amount as a decimal string for DECIMAL columns.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Scan reads a DECIMAL column in DefaultCurrency.
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*m = Zero(DefaultCurrency)
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+
+	parsed, err := parseScanned(s)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
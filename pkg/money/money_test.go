@@ -0,0 +1,228 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency Currency
+		expected int64
+		wantErr  error
+	}{
+		{in: "6.50", currency: USD, expected: 650},
+		{in: "6.5", currency: USD, expected: 650},
+		{in: "100", currency: USD, expected: 10000},
+		{in: "-3.01", currency: USD, expected: -301},
+		{in: ".99", currency: USD, expected: 99},
+		{in: "6.5000", currency: USD, expected: 650},
+		{in: "1500", currency: JPY, expected: 1500},
+		{in: "1.234", currency: "KWD", expected: 1234},
+		{in: "6.505", currency: USD, wantErr: ErrInvalidAmount},
+		{in: "1.5", currency: JPY, wantErr: ErrInvalidAmount},
+		{in: "", currency: USD, wantErr: ErrInvalidAmount},
+		{in: "ten", currency: USD, wantErr: ErrInvalidAmount},
+		{in: "1e3", currency: USD, wantErr: ErrInvalidAmount},
+		{in: "99999999999999999999", currency: USD, wantErr: ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			m, err := Parse(tt.in, tt.currency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (m.Minor() != tt.expected || m.Currency() != tt.currency) {
+				t.Errorf("expected %d %s, got %v", tt.expected, tt.currency, m)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	price := New(650, USD)
+
+	if sum, err := price.Add(New(450, USD)); err != nil || sum != New(1100, USD) {
+		t.Errorf("expected 11.00 USD, got %v %v", sum, err)
+	}
+	if diff, err := price.Sub(New(1000, USD)); err != nil || diff != New(-350, USD) {
+		t.Errorf("expected -3.50 USD, got %v %v", diff, err)
+	}
+	if total, err := price.Mul(3); err != nil || total != New(1950, USD) {
+		t.Errorf("expected 19.50 USD, got %v %v", total, err)
+	}
+	if sum, err := (Money{}).Add(price); err != nil || sum != price {
+		t.Errorf("expected the zero value to take the currency, got %v %v", sum, err)
+	}
+
+	if _, err := price.Add(New(1, EUR)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected currency mismatch, got %v", err)
+	}
+	if _, err := price.Cmp(New(1, EUR)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected currency mismatch, got %v", err)
+	}
+	if _, err := New(math.MaxInt64, USD).Add(New(1, USD)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := New(math.MinInt64, USD).Sub(New(1, USD)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := New(math.MaxInt64/2+1, USD).Mul(2); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if _, err := New(math.MinInt64, USD).Mul(-1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+
+	if c, err := price.Cmp(New(700, USD)); err != nil || c != -1 {
+		t.Errorf("expected -1, got %d %v", c, err)
+	}
+	if m, err := price.Min(New(700, USD)); err != nil || m != price {
+		t.Errorf("expected %v, got %v %v", price, m, err)
+	}
+}
+
+func TestMulRat(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		num, den int64
+		mode     RoundingMode
+		expected int64
+	}{
+		{name: "exact", amount: 1000, num: 1, den: 4, mode: HalfUp, expected: 250},
+		{name: "half up", amount: 5, num: 1, den: 2, mode: HalfUp, expected: 3},
+		{name: "half up negative", amount: -5, num: 1, den: 2, mode: HalfUp, expected: -3},
+		{name: "half even down", amount: 5, num: 1, den: 2, mode: HalfEven, expected: 2},
+		{name: "half even up", amount: 7, num: 1, den: 2, mode: HalfEven, expected: 4},
+		{name: "below half", amount: 10, num: 1, den: 3, mode: HalfUp, expected: 3},
+		{name: "above half", amount: 20, num: 1, den: 3, mode: HalfEven, expected: 7},
+		{name: "down", amount: 20, num: 1, den: 3, mode: Down, expected: 6},
+		{name: "up", amount: 10, num: 1, den: 3, mode: Up, expected: 4},
+		{name: "up negative", amount: -10, num: 1, den: 3, mode: Up, expected: -4},
+		{name: "negative denominator", amount: 10, num: 1, den: -4, mode: HalfUp, expected: -3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.amount, USD).MulRat(tt.num, tt.den, tt.mode)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Minor() != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got.Minor())
+			}
+		})
+	}
+
+	if _, err := New(1, USD).MulRat(1, 0, HalfUp); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("expected division by zero, got %v", err)
+	}
+	if _, err := New(math.MaxInt64, USD).MulRat(3, 2, HalfUp); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+
+	// 10% of 19.99 is 1.999
+	if got, _ := New(1999, USD).Percent(10, HalfUp); got.Minor() != 200 {
+		t.Errorf("expected 200, got %d", got.Minor())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m       Money
+		decimal string
+		json    string
+	}{
+		{m: New(650, USD), decimal: "6.50", json: "6.5"},
+		{m: New(10000, USD), decimal: "100.00", json: "100"},
+		{m: New(5, USD), decimal: "0.05", json: "0.05"},
+		{m: New(-1, USD), decimal: "-0.01", json: "-0.01"},
+		{m: New(0, USD), decimal: "0.00", json: "0"},
+		{m: Money{}, decimal: "0.00", json: "0"},
+		{m: New(1500, JPY), decimal: "1500", json: "1500"},
+		{m: New(math.MinInt64, USD), decimal: "-92233720368547758.08", json: "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decimal, func(t *testing.T) {
+			if got := tt.m.Decimal(); got != tt.decimal {
+				t.Errorf("expected decimal %s, got %s", tt.decimal, got)
+			}
+			b, err := json.Marshal(tt.m)
+			if err != nil || string(b) != tt.json {
+				t.Errorf("expected JSON %s, got %s %v", tt.json, b, err)
+			}
+		})
+	}
+
+	if f := New(-650, USD).Float64(); f != -6.5 {
+		t.Errorf("expected -6.5, got %v", f)
+	}
+	if f := New(1500, JPY).Float64(); f != 1500 {
+		t.Errorf("expected 1500, got %v", f)
+	}
+
+	if s := New(650, USD).String(); s != "6.50 USD" {
+		t.Errorf("expected 6.50 USD, got %s", s)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var v struct {
+		Price Money  `json:"price"`
+		Total *Money `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":6.5,"total":"20"}`), &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.Price != New(650, DefaultCurrency) {
+		t.Errorf("expected 6.50, got %v", v.Price)
+	}
+	if v.Total == nil || *v.Total != New(2000, DefaultCurrency) {
+		t.Errorf("expected 20.00, got %v", v.Total)
+	}
+
+	for _, price := range []string{`"six"`, `6.555`, `-0.001`, `"19.999"`, `1e3`} {
+		if err := json.Unmarshal([]byte(`{"price":`+price+`}`), &v); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("expected invalid amount for %s, got %v", price, err)
+		}
+	}
+}
+
+func TestScanValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      any
+		expected int64
+	}{
+		{name: "bytes", src: []byte("6.50"), expected: 650},
+		{name: "string", src: "1234.5", expected: 123450},
+		{name: "int", src: int64(7), expected: 700},
+		{name: "float", src: 0.1, expected: 10},
+		{name: "rounded", src: "6.555", expected: 656},
+		{name: "null", src: nil, expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			if err := m.Scan(tt.src); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if m != New(tt.expected, DefaultCurrency) {
+				t.Errorf("expected %d, got %v", tt.expected, m)
+			}
+		})
+	}
+
+	var m Money
+	if err := m.Scan(true); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected invalid amount, got %v", err)
+	}
+
+	v, err := New(650, USD).Value()
+	if err != nil || v != "6.50" {
+		t.Errorf("expected 6.50, got %v %v", v, err)
+	}
+}
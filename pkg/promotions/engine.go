@@ -0,0 +1,210 @@
+package promotions
+
+import (
+	"math"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
+// RuleType identifies how a promotion rule discounts an order.
+type RuleType string
+
+const (
+	// PercentageOff takes Percentage off the order after item level discounts.
+	PercentageOff RuleType = "percentage_off"
+	// FixedAmountOff takes Amount off the order after all other discounts.
+	FixedAmountOff RuleType = "fixed_amount_off"
+	// BuyXGetY makes FreeQuantity of every BuyQuantity+FreeQuantity units of ProductID free.
+	BuyXGetY RuleType = "buy_x_get_y"
+	// FreeCheapestItem makes the cheapest unit free once the order has MinQuantity units.
+	FreeCheapestItem RuleType = "free_cheapest_item"
+	// CategoryDiscount takes Percentage off every line in Category.
+	CategoryDiscount RuleType = "category_discount"
+)
+
+// Rule is a single discount rule of a promotion.
+type Rule struct {
+	Type         RuleType
+	Percentage   float64
+	Amount       money.Money
+	ProductID    uint
+	BuyQuantity  int
+	FreeQuantity int
+	MinQuantity  int
+	Category     string
+}
+
+// Line is an order line the rules are applied to.
+type Line struct {
+	ProductID uint
+	Category  string
+	UnitPrice money.Money
+	Quantity  int
+}
+
+// Result is the outcome of applying the rules to an order.
+type Result struct {
+	Subtotal money.Money
+	Discount money.Money
+	Total    money.Money
+}
+
+// Apply calculates the discount the rules grant on the order lines. Item level rules
+// (buy X get Y, free cheapest item, category discount) are applied first, then
+// percentage off on the remaining amount and finally fixed amounts off. The discount
+// never exceeds the subtotal and percentages are rounded half up to minor units.
+// It fails when the amounts are in different currencies or overflow.
+func Apply(lines []Line, rules []Rule) (Result, error) {
+	var subtotal money.Money
+	for _, l := range lines {
+		lineTotal, err := l.UnitPrice.Mul(int64(l.Quantity))
+		if err != nil {
+			return Result{}, err
+		}
+		if subtotal, err = subtotal.Add(lineTotal); err != nil {
+			return Result{}, err
+		}
+	}
+
+	var itemDiscount money.Money
+	for _, r := range rules {
+		var d money.Money
+		var err error
+		switch r.Type {
+		case BuyXGetY:
+			d, err = buyXGetY(lines, r)
+		case FreeCheapestItem:
+			d, err = freeCheapestItem(lines, r)
+		case CategoryDiscount:
+			d, err = categoryDiscount(lines, r)
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		if itemDiscount, err = itemDiscount.Add(d); err != nil {
+			return Result{}, err
+		}
+	}
+
+	discount, err := itemDiscount.Min(subtotal)
+	if err != nil {
+		return Result{}, err
+	}
+
+	for _, r := range rules {
+		if r.Type != PercentageOff {
+			continue
+		}
+
+		remaining, err := subtotal.Sub(discount)
+		if err != nil {
+			return Result{}, err
+		}
+		off, err := remaining.Percent(clampPercentage(r.Percentage), money.HalfUp)
+		if err != nil {
+			return Result{}, err
+		}
+		if discount, err = discount.Add(off); err != nil {
+			return Result{}, err
+		}
+	}
+
+	for _, r := range rules {
+		if r.Type != FixedAmountOff || !r.Amount.IsPositive() {
+			continue
+		}
+
+		remaining, err := subtotal.Sub(discount)
+		if err != nil {
+			return Result{}, err
+		}
+		off, err := r.Amount.Min(remaining)
+		if err != nil {
+			return Result{}, err
+		}
+		if discount, err = discount.Add(off); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if discount, err = discount.Min(subtotal); err != nil {
+		return Result{}, err
+	}
+	total, err := subtotal.Sub(discount)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Subtotal: subtotal, Discount: discount, Total: total}, nil
+}
+
+func buyXGetY(lines []Line, r Rule) (money.Money, error) {
+	if r.BuyQuantity <= 0 || r.FreeQuantity <= 0 {
+		return money.Money{}, nil
+	}
+
+	quantity := 0
+	var unitPrice money.Money
+	for _, l := range lines {
+		if l.ProductID == r.ProductID {
+			quantity += l.Quantity
+			unitPrice = l.UnitPrice
+		}
+	}
+
+	free := quantity / (r.BuyQuantity + r.FreeQuantity) * r.FreeQuantity
+	return unitPrice.Mul(int64(free))
+}
+
+func freeCheapestItem(lines []Line, r Rule) (money.Money, error) {
+	units := 0
+	var cheapest *money.Money
+	for _, l := range lines {
+		if l.Quantity <= 0 {
+			continue
+		}
+
+		units += l.Quantity
+		if cheapest == nil {
+			cheapest = &l.UnitPrice
+			continue
+		}
+
+		c, err := l.UnitPrice.Cmp(*cheapest)
+		if err != nil {
+			return money.Money{}, err
+		}
+		if c < 0 {
+			cheapest = &l.UnitPrice
+		}
+	}
+
+	if cheapest == nil || units < max(r.MinQuantity, 1) {
+		return money.Money{}, nil
+	}
+
+	return *cheapest, nil
+}
+
+func categoryDiscount(lines []Line, r Rule) (money.Money, error) {
+	var categoryTotal money.Money
+	for _, l := range lines {
+		if l.Category != r.Category {
+			continue
+		}
+
+		lineTotal, err := l.UnitPrice.Mul(int64(l.Quantity))
+		if err != nil {
+			return money.Money{}, err
+		}
+		if categoryTotal, err = categoryTotal.Add(lineTotal); err != nil {
+			return money.Money{}, err
+		}
+	}
+
+	return categoryTotal.Percent(clampPercentage(r.Percentage), money.HalfUp)
+}
+
+func clampPercentage(p float64) float64 {
+	return math.Max(0, math.Min(p, 100))
+}
@@ -0,0 +1,137 @@
+package promotions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
+func usd(s string) money.Money {
+	return money.MustParse(s, money.USD)
+}
+
+func TestApply(t *testing.T) {
+	lines := []Line{
+		{ProductID: 1, Category: "Waffle", UnitPrice: usd("6.5"), Quantity: 3},
+		{ProductID: 2, Category: "Brownie", UnitPrice: usd("4.5"), Quantity: 1},
+		{ProductID: 3, Category: "Cake", UnitPrice: usd("7"), Quantity: 2},
+	}
+	// subtotal: 19.5 + 4.5 + 14 = 38
+
+	tests := []struct {
+		name     string
+		rules    []Rule
+		expected Result
+	}{
+		{
+			name:     "no rules",
+			expected: Result{Subtotal: usd("38"), Discount: usd("0"), Total: usd("38")},
+		},
+		{
+			name:     "percentage off",
+			rules:    []Rule{{Type: PercentageOff, Percentage: 10}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("3.8"), Total: usd("34.2")},
+		},
+		{
+			name:     "fixed amount off",
+			rules:    []Rule{{Type: FixedAmountOff, Amount: usd("5")}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("5"), Total: usd("33")},
+		},
+		{
+			name:     "fixed amount capped at subtotal",
+			rules:    []Rule{{Type: FixedAmountOff, Amount: usd("100")}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("38"), Total: usd("0")},
+		},
+		{
+			name:     "buy two get one",
+			rules:    []Rule{{Type: BuyXGetY, ProductID: 1, BuyQuantity: 2, FreeQuantity: 1}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("6.5"), Total: usd("31.5")},
+		},
+		{
+			name:     "buy x get y not reached",
+			rules:    []Rule{{Type: BuyXGetY, ProductID: 3, BuyQuantity: 2, FreeQuantity: 1}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("0"), Total: usd("38")},
+		},
+		{
+			name:     "free cheapest item",
+			rules:    []Rule{{Type: FreeCheapestItem, MinQuantity: 6}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("4.5"), Total: usd("33.5")},
+		},
+		{
+			name:     "free cheapest item below minimum",
+			rules:    []Rule{{Type: FreeCheapestItem, MinQuantity: 7}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("0"), Total: usd("38")},
+		},
+		{
+			name:     "category discount",
+			rules:    []Rule{{Type: CategoryDiscount, Category: "Cake", Percentage: 50}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("7"), Total: usd("31")},
+		},
+		{
+			name: "item level before percentage before fixed",
+			rules: []Rule{
+				{Type: FixedAmountOff, Amount: usd("2")},
+				{Type: PercentageOff, Percentage: 10},
+				{Type: CategoryDiscount, Category: "Waffle", Percentage: 100},
+			},
+			// 38 - 19.5 = 18.5, 10% = 1.85, then 2 off
+			expected: Result{Subtotal: usd("38"), Discount: usd("23.35"), Total: usd("14.65")},
+		},
+		{
+			name:     "unknown rule type is ignored",
+			rules:    []Rule{{Type: "mystery", Amount: usd("10")}},
+			expected: Result{Subtotal: usd("38"), Discount: usd("0"), Total: usd("38")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(lines, tt.rules)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApply_BuyXGetYAcrossLines(t *testing.T) {
+	lines := []Line{
+		{ProductID: 1, UnitPrice: usd("2"), Quantity: 2},
+		{ProductID: 1, UnitPrice: usd("2"), Quantity: 2},
+	}
+	got, err := Apply(lines, []Rule{{Type: BuyXGetY, ProductID: 1, BuyQuantity: 1, FreeQuantity: 1}})
+	if err != nil || got.Discount != usd("4") {
+		t.Errorf("expected discount 4, got %v %v", got.Discount, err)
+	}
+}
+
+func TestApply_NoRoundingDrift(t *testing.T) {
+	// 0.1 + 0.2 style float errors must not leak into totals
+	lines := []Line{
+		{ProductID: 1, UnitPrice: usd("0.10"), Quantity: 3},
+		{ProductID: 2, UnitPrice: usd("0.20"), Quantity: 1},
+		{ProductID: 3, UnitPrice: usd("19.99"), Quantity: 7},
+	}
+	got, err := Apply(lines, []Rule{{Type: PercentageOff, Percentage: 15}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// subtotal 140.43, 15% is 21.0645
+	expected := Result{Subtotal: usd("140.43"), Discount: usd("21.06"), Total: usd("119.37")}
+	if got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestApply_CurrencyMismatch(t *testing.T) {
+	lines := []Line{
+		{ProductID: 1, UnitPrice: usd("1"), Quantity: 1},
+		{ProductID: 2, UnitPrice: money.MustParse("1", money.EUR), Quantity: 1},
+	}
+	if _, err := Apply(lines, nil); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("expected currency mismatch, got %v", err)
+	}
+}
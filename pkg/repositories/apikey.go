@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	errors2 "errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type APIKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepo(db *gorm.DB) APIKeyRepo {
+	return APIKeyRepo{db: db}
+}
+
+// FindByHash returns the API key with the given hash unless it was revoked.
+func (r *APIKeyRepo) FindByHash(ctx context.Context, hash string) (*db.APIKey, error) {
+	var key db.APIKey
+	err := r.db.WithContext(ctx).First(&key, "key_hash = ? AND revoked_at IS NULL", hash).Error
+	if errors2.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error fetching api key: %v", err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return &key, nil
+}
+
+// TouchLastUsed records when the API key was last used.
+func (r *APIKeyRepo) TouchLastUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&db.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error updating api key %s last use: %v", id, err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Create stores a new API key.
+func (r *APIKeyRepo) Create(ctx context.Context, key *db.APIKey) error {
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error creating api key: %v", err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// FindByIDForUpdate returns the API key with the given ID, revoked keys included. The key
+// is locked until the end of the transaction, so concurrent rotations or revocations of
+// the key are serialised.
+func (r *APIKeyRepo) FindByIDForUpdate(ctx context.Context, id uuid.UUID) (*db.APIKey, error) {
+	var key db.APIKey
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, "id = ?", id).Error
+	if errors2.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error locking api key %s: %v", id, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return &key, nil
+}
+
+// FindAll lists all API keys, newest first.
+func (r *APIKeyRepo) FindAll(ctx context.Context) ([]db.APIKey, error) {
+	var keys []db.APIKey
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error listing api keys: %v", err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return keys, nil
+}
+
+// Save updates the expiry, revocation and replacement of the API key.
+func (r *APIKeyRepo) Save(ctx context.Context, key *db.APIKey) error {
+	err := r.db.WithContext(ctx).Model(key).
+		Select("expires_at", "revoked_at", "replaced_by").
+		Updates(key).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error updating api key %s: %v", key.ID, err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Audit records a change of an API key.
+func (r *APIKeyRepo) Audit(ctx context.Context, entry *db.APIKeyAudit) error {
+	if err := r.db.WithContext(ctx).Create(entry).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error recording api key %s audit: %v", entry.APIKeyID, err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	errors2 "errors"
+
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"gorm.io/gorm"
+)
+
+type CategoryRepo struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepo(db *gorm.DB) CategoryRepo {
+	return CategoryRepo{db: db}
+}
+
+// FindActive lists the active categories in menu order.
+func (r *CategoryRepo) FindActive(ctx context.Context) ([]db.Category, error) {
+	var categories []db.Category
+	if err := r.db.WithContext(ctx).Where("active").Order("display_order, name").Find(&categories).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error listing categories: %v", err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return categories, nil
+}
+
+// FindActiveBySlug returns the active category with the given slug.
+func (r *CategoryRepo) FindActiveBySlug(ctx context.Context, slug string) (*db.Category, error) {
+	return r.first(ctx, "slug = ? AND active", slug)
+}
+
+// FindBySlug returns the category with the given slug, active or not.
+func (r *CategoryRepo) FindBySlug(ctx context.Context, slug string) (*db.Category, error) {
+	return r.first(ctx, "slug = ?", slug)
+}
+
+func (r *CategoryRepo) Create(ctx context.Context, category *db.Category) error {
+	if err := r.db.WithContext(ctx).Create(category).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error creating category: %v", err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+func (r *CategoryRepo) first(ctx context.Context, query string, args ...any) (*db.Category, error) {
+	var category db.Category
+	err := r.db.WithContext(ctx).Where(query, args...).First(&category).Error
+	if errors2.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.ErrCategoryNotFound
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error fetching category %v: %v", args, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return &category, nil
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepo struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepo(db *gorm.DB) IdempotencyRepo {
+	return IdempotencyRepo{db: db}
+}
+
+// Reserve claims the idempotency key for a new request. When the key is already taken
+// and not expired it returns the stored record and false.
+func (r *IdempotencyRepo) Reserve(ctx context.Context, rec *db.IdempotencyKey) (*db.IdempotencyKey, bool, error) {
+	var existing db.IdempotencyKey
+	reserved := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("client_id = ? AND key = ? AND expires_at <= ?", rec.ClientID, rec.Key, time.Now()).
+			Delete(&db.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(rec)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			reserved = true
+			return nil
+		}
+
+		return tx.First(&existing, "client_id = ? AND key = ?", rec.ClientID, rec.Key).Error
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error reserving idempotency key %s: %v", rec.Key, err)
+		return nil, false, errors.ErrDatabaseError
+	}
+
+	if reserved {
+		return rec, true, nil
+	}
+
+	return &existing, false, nil
+}
+
+// Complete stores the response of the request that reserved the key.
+func (r *IdempotencyRepo) Complete(ctx context.Context, clientID, key string, statusCode int, body []byte) error {
+	err := r.db.WithContext(ctx).Model(&db.IdempotencyKey{}).
+		Where("client_id = ? AND key = ?", clientID, key).
+		Updates(map[string]interface{}{"status_code": statusCode, "response_body": body}).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error storing idempotent response for key %s: %v", key, err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Release frees the key again, so a request that failed can be retried with it.
+func (r *IdempotencyRepo) Release(ctx context.Context, clientID, key string) error {
+	err := r.db.WithContext(ctx).Where("client_id = ? AND key = ?", clientID, key).
+		Delete(&db.IdempotencyKey{}).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error releasing idempotency key %s: %v", key, err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes the expired keys of every client and returns how many were deleted.
+func (r *IdempotencyRepo) PurgeExpired(ctx context.Context) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&db.IdempotencyKey{})
+	if res.Error != nil {
+		log.WithCtx(ctx).Error().Msgf("error purging expired idempotency keys: %v", res.Error)
+		return 0, errors.ErrDatabaseError
+	}
+
+	return res.RowsAffected, nil
+}
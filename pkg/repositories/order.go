@@ -2,8 +2,14 @@ package repositories
 
 import (
 	"context"
+	errors2 "errors"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
 	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/money"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -16,11 +22,105 @@ func NewOrderRepo(db *gorm.DB) OrderRepo {
 	return OrderRepo{db: db}
 }
 
-// Create inserts a new order with products
+// Create inserts a new order with its products, status history and coupon redemption.
+// Use it through UnitOfWork.Do to insert the order in the same transaction as the
+// redemption limit checks.
 func (r *OrderRepo) Create(ctx context.Context, order *db.Order) error {
-	if err := r.db.WithContext(ctx).Clauses(clause.Returning{}).Create(&order).Error; err != nil {
-		return err
+	if err := r.db.WithContext(ctx).Clauses(clause.Returning{}).Create(order).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error creating order: %v", err)
+		return errors.ErrDatabaseError
 	}
 
 	return nil
 }
+
+// FindByID loads an order with its products.
+func (r *OrderRepo) FindByID(ctx context.Context, id uuid.UUID) (*db.Order, error) {
+	var order db.Order
+	err := r.db.WithContext(ctx).Preload("Products").
+		Preload("History", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at, id") }).
+		First(&order, "id = ?", id).Error
+	if errors2.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.ErrOrderNotFound
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error fetching order %s: %v", id, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return &order, nil
+}
+
+// UpdateStatus moves the order from change.FromStatus to change.ToStatus and records the
+// change. It fails with ErrInvalidStatusTransition when the order is no longer in the
+// expected status, e.g. because of a concurrent update.
+func (r *OrderRepo) UpdateStatus(ctx context.Context, change *db.OrderStatusHistory) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&db.Order{}).
+			Where("id = ? AND status = ?", change.OrderID, change.FromStatus).
+			Update("status", change.ToStatus)
+		if res.Error != nil {
+			log.WithCtx(ctx).Error().Msgf("error updating order %s status: %v", change.OrderID, res.Error)
+			return errors.ErrDatabaseError
+		}
+		if res.RowsAffected == 0 {
+			return errors.ErrInvalidStatusTransition
+		}
+
+		if err := tx.Create(change).Error; err != nil {
+			log.WithCtx(ctx).Error().Msgf("error recording order %s status change: %v", change.OrderID, err)
+			return errors.ErrDatabaseError
+		}
+
+		return nil
+	})
+}
+
+// OrderCursor is the position of the last order of a page, orders are listed newest first.
+type OrderCursor struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// OrderFilter narrows down FindAll, nil or empty fields are not filtered on.
+type OrderFilter struct {
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	CouponCode  string
+	MinTotal    *money.Money
+	MaxTotal    *money.Money
+	After       *OrderCursor
+	Limit       int
+}
+
+// FindAll lists orders newest first using keyset pagination on (created_at, id).
+func (r *OrderRepo) FindAll(ctx context.Context, f OrderFilter) ([]db.Order, error) {
+	query := r.db.WithContext(ctx).Preload("Products")
+	if f.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		query = query.Where("created_at < ?", *f.CreatedTo)
+	}
+	if f.CouponCode != "" {
+		query = query.Where("coupon_code = ?", f.CouponCode)
+	}
+	if f.MinTotal != nil {
+		query = query.Where("total >= ?", *f.MinTotal)
+	}
+	if f.MaxTotal != nil {
+		query = query.Where("total <= ?", *f.MaxTotal)
+	}
+	if f.After != nil {
+		query = query.Where("(created_at, id) < (?, ?)", f.After.CreatedAt, f.After.ID)
+	}
+
+	var orders []db.Order
+	err := query.Order("created_at DESC").Order("id DESC").Limit(f.Limit).Find(&orders).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error listing orders: %v", err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return orders, nil
+}
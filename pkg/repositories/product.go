@@ -2,11 +2,21 @@ package repositories
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	errors2 "errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/malakagl/kart-challenge/pkg/errors"
 	"github.com/malakagl/kart-challenge/pkg/log"
 	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/money"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepo struct {
@@ -17,15 +27,160 @@ func NewProductRepo(db *gorm.DB) ProductRepo {
 	return ProductRepo{db: db}
 }
 
-func (r *ProductRepo) FindAll(ctx context.Context) ([]db.Product, error) {
+// Columns products can be sorted by
+const (
+	ProductSortPrice     = "price"
+	ProductSortName      = "name"
+	ProductSortCreatedAt = "created_at"
+)
+
+// ProductCursor is the position of the last product of a page. It holds the value of the
+// sort column so the next page continues after it, and the sort and filters it was made for.
+type ProductCursor struct {
+	Sort    string `json:"sort"`
+	Desc    bool   `json:"desc"`
+	Filters string `json:"filters"` // hash of the filters of the listing
+	Value   string `json:"value"`
+	ID      uint   `json:"id"`
+}
+
+// NewProductCursor returns the cursor pointing after p in the sort and filters of f.
+func NewProductCursor(p *db.Product, f ProductFilter) ProductCursor {
+	c := ProductCursor{Sort: f.Sort, Desc: f.Desc, Filters: f.hash(), ID: p.ID}
+	switch f.Sort {
+	case ProductSortPrice:
+		c.Value = p.Price.Decimal()
+	case ProductSortName:
+		c.Value = p.Name
+	default:
+		c.Value = p.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return c
+}
+
+// value converts the sort column value of the cursor to its column type.
+func (c ProductCursor) value() (any, error) {
+	switch c.Sort {
+	case ProductSortPrice:
+		return money.Parse(c.Value, money.DefaultCurrency)
+	case ProductSortName:
+		return c.Value, nil
+	case ProductSortCreatedAt:
+		return time.Parse(time.RFC3339Nano, c.Value)
+	default:
+		return nil, fmt.Errorf("unknown product sort %q", c.Sort)
+	}
+}
+
+// Valid tells whether the cursor was made for the sort and filters of f and holds a usable
+// value. A cursor of other filters would skip or repeat products.
+func (c ProductCursor) Valid(f ProductFilter) bool {
+	if c.Sort != f.Sort || c.Desc != f.Desc || c.Filters != f.hash() {
+		return false
+	}
+	_, err := c.value()
+	return err == nil
+}
+
+// ProductFilter narrows down and orders FindAll, nil or empty fields are not filtered on.
+type ProductFilter struct {
+	CategorySlug string // slug of the category, matched through the categories table
+	CategoryID   uint
+	MinPrice     *money.Money
+	MaxPrice     *money.Money
+	Query        string // part of the name, case insensitive
+	Sort         string // one of the ProductSort columns, created_at when empty
+	Desc         bool
+	After        *ProductCursor
+	Limit        int
+}
+
+// hash identifies the filters of f, sort and page are left out.
+func (f ProductFilter) hash() string {
+	price := func(m *money.Money) string {
+		if m == nil {
+			return ""
+		}
+		return m.Decimal()
+	}
+
+	h := sha256.New()
+	for _, v := range []string{f.CategorySlug, strconv.FormatUint(uint64(f.CategoryID), 10), price(f.MinPrice), price(f.MaxPrice), f.Query} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// FindAll lists products using keyset pagination on the sort column and id. The sort
+// column is picked from the ProductSort constants, never taken from the caller as is.
+func (r *ProductRepo) FindAll(ctx context.Context, f ProductFilter) ([]db.Product, error) {
+	sort := f.Sort
+	if sort != ProductSortPrice && sort != ProductSortName {
+		sort = ProductSortCreatedAt
+	}
+	direction, cmp := "ASC", ">"
+	if f.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	query := r.db.WithContext(ctx).Preload("Image")
+	if f.CategorySlug != "" {
+		query = query.Where("category_id = (SELECT id FROM categories WHERE slug = ?)", f.CategorySlug)
+	}
+	if f.CategoryID != 0 {
+		query = query.Where("category_id = ?", f.CategoryID)
+	}
+	if f.MinPrice != nil {
+		query = query.Where("price >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		query = query.Where("price <= ?", *f.MaxPrice)
+	}
+	if f.Query != "" {
+		query = query.Where("name ILIKE ?", "%"+escapeLike(f.Query)+"%")
+	}
+	if f.After != nil {
+		value, err := f.After.value()
+		if err != nil {
+			return nil, errors.ErrInvalidCursor
+		}
+		query = query.Where("("+sort+", id) "+cmp+" (?, ?)", value, f.After.ID)
+	}
+
 	var products []db.Product
-	if err := r.db.WithContext(ctx).Preload("Image").Find(&products).Error; err != nil {
-		return nil, errors.ErrProductNotFound
+	err := query.Order(sort + " " + direction).Order("id " + direction).Limit(f.Limit).Find(&products).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error listing products: %v", err)
+		return nil, errors.ErrDatabaseError
 	}
 
 	return products, nil
 }
 
+// LastModified is when the catalog last changed, i.e. when a product was last created,
+// updated or deleted. It is zero when there are no products.
+func (r *ProductRepo) LastModified(ctx context.Context) (time.Time, error) {
+	var last *time.Time
+	err := r.db.WithContext(ctx).Unscoped().Model(&db.Product{}).
+		Select("MAX(GREATEST(updated_at, deleted_at))").Scan(&last).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error fetching catalog modification time: %v", err)
+		return time.Time{}, errors.ErrDatabaseError
+	}
+	if last == nil {
+		return time.Time{}, nil
+	}
+
+	return *last, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func (r *ProductRepo) FindByID(ctx context.Context, id uint) (*db.Product, error) {
 	var product db.Product
 	if err := r.db.WithContext(ctx).Preload("Image").First(&product, "id = ?", id).Error; err != nil {
@@ -39,3 +194,175 @@ func (r *ProductRepo) FindByID(ctx context.Context, id uint) (*db.Product, error
 
 	return &product, nil
 }
+
+// FindByIDs loads all products with the given IDs in a single query.
+func (r *ProductRepo) FindByIDs(ctx context.Context, ids []uint) ([]db.Product, error) {
+	var products []db.Product
+	if err := r.db.WithContext(ctx).Preload("Image").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching products %v: %v", ids, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return products, nil
+}
+
+// FindByIDsUnscoped loads the products with the given IDs, deleted ones included, so
+// orders placed before a product was deleted still resolve it.
+func (r *ProductRepo) FindByIDsUnscoped(ctx context.Context, ids []uint) ([]db.Product, error) {
+	var products []db.Product
+	if err := r.db.WithContext(ctx).Unscoped().Preload("Image").Where("id IN ?", ids).Find(&products).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching products %v: %v", ids, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return products, nil
+}
+
+// FindByIDForUpdate returns the product with the given ID and its image. The product is
+// locked until the end of the transaction, so concurrent updates of it are serialised.
+func (r *ProductRepo) FindByIDForUpdate(ctx context.Context, id uint) (*db.Product, error) {
+	var product db.Product
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", id).Error
+	if errors2.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.ErrProductNotFound
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error locking product %d: %v", id, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	if err := r.db.WithContext(ctx).First(&product.Image, "product_id = ?", id).Error; err != nil &&
+		!errors2.Is(err, gorm.ErrRecordNotFound) {
+		log.WithCtx(ctx).Error().Msgf("error fetching image of product %d: %v", id, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return &product, nil
+}
+
+// Create stores a new product together with its image.
+func (r *ProductRepo) Create(ctx context.Context, product *db.Product) error {
+	if err := r.db.WithContext(ctx).Create(product).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error creating product: %v", err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Save updates a product and its image, the image is created when the product has none.
+func (r *ProductRepo) Save(ctx context.Context, product *db.Product) error {
+	tx := r.db.WithContext(ctx)
+	if err := tx.Omit(clause.Associations).Save(product).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error updating product %d: %v", product.ID, err)
+		return errors.ErrDatabaseError
+	}
+
+	product.Image.ProductID = product.ID
+	if err := tx.Save(&product.Image).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error updating image of product %d: %v", product.ID, err)
+		return errors.ErrDatabaseError
+	}
+
+	return nil
+}
+
+// Delete soft deletes the product, it disappears from the catalog but orders keep it.
+func (r *ProductRepo) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Delete(&db.Product{}, id)
+	if res.Error != nil {
+		log.WithCtx(ctx).Error().Msgf("error deleting product %d: %v", id, res.Error)
+		return errors.ErrDatabaseError
+	}
+	if res.RowsAffected == 0 {
+		return errors.ErrProductNotFound
+	}
+
+	return nil
+}
+
+// headlineOptions marks the matched words of a search headline. Names and categories are
+// short, so the whole text is returned instead of a fragment.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+// maxSearchTerms caps the words of a search query that are matched.
+const maxSearchTerms = 10
+
+// ProductSearch is a full text search of product names and categories.
+type ProductSearch struct {
+	Query string
+	Fuzzy bool // also match names and categories similar to the query, for typos
+	Limit int
+}
+
+// ProductMatch is a product found by Search, with its rank and the HTML escaped name and
+// category with the matched words wrapped in <mark> tags.
+type ProductMatch struct {
+	ProductID         uint
+	PrefixMatch       bool // the product has words starting with every word of the query
+	Rank              float64
+	NameHighlight     string
+	CategoryHighlight string
+}
+
+// Search finds products whose name or category contains words starting with the words of
+// the query, best matches first. With Fuzzy set, products whose name and category are
+// similar to the query are found too, ranked after every prefix match.
+func (r *ProductRepo) Search(ctx context.Context, s ProductSearch) ([]ProductMatch, error) {
+	tsQuery := prefixTSQuery(s.Query)
+	if tsQuery == "" {
+		return nil, nil
+	}
+
+	matched := "search_vector @@ to_tsquery('simple', ?)"
+	rank, selectArgs := "ts_rank(search_vector, to_tsquery('simple', ?))", []any{tsQuery, tsQuery}
+	where, whereArgs := matched, []any{tsQuery}
+	if s.Fuzzy {
+		// similarity ranks the fuzzy matches among themselves, ts_rank the prefix matches
+		rank = "CASE WHEN " + matched + " THEN " + rank + " ELSE word_similarity(?, name || ' ' || category) END"
+		selectArgs = []any{tsQuery, tsQuery, tsQuery, s.Query}
+		where += " OR ? <% (name || ' ' || category)"
+		whereArgs = append(whereArgs, s.Query)
+	}
+	selectArgs = append(selectArgs, tsQuery, headlineOptions, tsQuery, headlineOptions)
+
+	var matches []ProductMatch
+	err := r.db.WithContext(ctx).Model(&db.Product{}).
+		Select("id AS product_id, "+matched+" AS prefix_match, "+rank+" AS rank, "+
+			"ts_headline('simple', "+htmlEscaped("name")+", to_tsquery('simple', ?), ?) AS name_highlight, "+
+			"ts_headline('simple', "+htmlEscaped("category")+", to_tsquery('simple', ?), ?) AS category_highlight", selectArgs...).
+		Where(where, whereArgs...).
+		Order("prefix_match DESC").Order("rank DESC").Order("id").
+		Limit(s.Limit).
+		Scan(&matches).Error
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error searching products for %q: %v", s.Query, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return matches, nil
+}
+
+// htmlEscaped escapes the HTML special characters of the column, so the <mark> tags are
+// the only markup of a headline. The parser reads the entities as entity tokens, never
+// as words, so they are not highlighted.
+func htmlEscaped(column string) string {
+	return "REPLACE(REPLACE(REPLACE(REPLACE(" + column + ", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '\"', '&quot;')"
+}
+
+// prefixTSQuery turns the words of a search query into a tsquery matching products that
+// have a word starting with each of them. Anything but letters and digits separates words,
+// so the query cannot inject tsquery operators.
+func prefixTSQuery(q string) string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	for i := range words {
+		words[i] += ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
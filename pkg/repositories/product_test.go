@@ -0,0 +1,254 @@
+package repositories
+
+import (
+	"context"
+	"database/sql/driver"
+	errors2 "errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
+func TestProductRepo_FindAll(t *testing.T) {
+	minPrice, maxPrice := money.MustParse("2.50", money.USD), money.MustParse("10.00", money.USD)
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter ProductFilter
+		sql    string
+		args   []any
+	}{
+		{
+			name:   "defaults",
+			filter: ProductFilter{Limit: 51},
+			sql:    `SELECT * FROM "products" WHERE "products"."deleted_at" IS NULL ORDER BY created_at ASC,id ASC LIMIT $1`,
+			args:   []any{51},
+		},
+		{
+			name:   "category",
+			filter: ProductFilter{CategorySlug: "waffle", Limit: 11},
+			sql:    `SELECT * FROM "products" WHERE category_id = (SELECT id FROM categories WHERE slug = $1) AND "products"."deleted_at" IS NULL ORDER BY created_at ASC,id ASC LIMIT $2`,
+			args:   []any{"waffle", 11},
+		},
+		{
+			name:   "price range",
+			filter: ProductFilter{MinPrice: &minPrice, MaxPrice: &maxPrice, Limit: 11},
+			sql:    `SELECT * FROM "products" WHERE price >= $1 AND price <= $2 AND "products"."deleted_at" IS NULL ORDER BY created_at ASC,id ASC LIMIT $3`,
+			args:   []any{"2.50", "10.00", 11},
+		},
+		{
+			name:   "name search escapes wildcards",
+			filter: ProductFilter{Query: "50%_off", Limit: 11},
+			sql:    `SELECT * FROM "products" WHERE name ILIKE $1 AND "products"."deleted_at" IS NULL ORDER BY created_at ASC,id ASC LIMIT $2`,
+			args:   []any{`%50\%\_off%`, 11},
+		},
+		{
+			name:   "price descending",
+			filter: ProductFilter{Sort: ProductSortPrice, Desc: true, Limit: 11},
+			sql:    `SELECT * FROM "products" WHERE "products"."deleted_at" IS NULL ORDER BY price DESC,id DESC LIMIT $1`,
+			args:   []any{11},
+		},
+		{
+			name:   "unknown sort falls back to created_at",
+			filter: ProductFilter{Sort: "id; DROP TABLE products", Limit: 11},
+			sql:    `SELECT * FROM "products" WHERE "products"."deleted_at" IS NULL ORDER BY created_at ASC,id ASC LIMIT $1`,
+			args:   []any{11},
+		},
+		{
+			name: "name cursor ascending",
+			filter: ProductFilter{Sort: ProductSortName, Limit: 11,
+				After: &ProductCursor{Sort: ProductSortName, Value: "Cake", ID: 4}},
+			sql:  `SELECT * FROM "products" WHERE (name, id) > ($1, $2) AND "products"."deleted_at" IS NULL ORDER BY name ASC,id ASC LIMIT $3`,
+			args: []any{"Cake", 4, 11},
+		},
+		{
+			name: "price cursor descending",
+			filter: ProductFilter{Sort: ProductSortPrice, Desc: true, Limit: 11,
+				After: &ProductCursor{Sort: ProductSortPrice, Desc: true, Value: "6.50", ID: 2}},
+			sql:  `SELECT * FROM "products" WHERE (price, id) < ($1, $2) AND "products"."deleted_at" IS NULL ORDER BY price DESC,id DESC LIMIT $3`,
+			args: []any{"6.50", 2, 11},
+		},
+		{
+			name: "every filter with created_at cursor",
+			filter: ProductFilter{CategorySlug: "waffle", MinPrice: &minPrice, Query: "choc", Limit: 6,
+				After: &ProductCursor{Sort: ProductSortCreatedAt, Value: created.Format(time.RFC3339Nano), ID: 9}},
+			sql: `SELECT * FROM "products" WHERE category_id = (SELECT id FROM categories WHERE slug = $1) AND price >= $2 AND name ILIKE $3 AND (created_at, id) > ($4, $5) ` +
+				`AND "products"."deleted_at" IS NULL ORDER BY created_at ASC,id ASC LIMIT $6`,
+			args: []any{"waffle", "2.50", "%choc%", created, 9, 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := testutil.NewMockDB(t)
+			args := make([]driver.Value, len(tt.args))
+			for i, a := range tt.args {
+				args[i] = argEquals{a}
+			}
+			mock.ExpectQuery("^" + regexp.QuoteMeta(tt.sql) + "$").WithArgs(args...).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+			repo := NewProductRepo(gormDB)
+			if _, err := repo.FindAll(context.Background(), tt.filter); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestProductRepo_FindAll_InvalidCursor(t *testing.T) {
+	gormDB, _ := testutil.NewMockDB(t)
+	repo := NewProductRepo(gormDB)
+
+	_, err := repo.FindAll(context.Background(), ProductFilter{Sort: ProductSortPrice, Limit: 1,
+		After: &ProductCursor{Sort: ProductSortPrice, Value: "cheap"}})
+	if !errors2.Is(err, errors.ErrInvalidCursor) {
+		t.Errorf("expected invalid cursor, got %v", err)
+	}
+}
+
+func TestProductCursor(t *testing.T) {
+	p := &db.Product{ID: 3, Name: "Waffle", Price: money.MustParse("6.50", money.USD),
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)}
+
+	minPrice, otherMinPrice := money.MustParse("5.00", money.USD), money.MustParse("1.00", money.USD)
+	for _, sort := range []string{ProductSortPrice, ProductSortName, ProductSortCreatedAt} {
+		f := ProductFilter{Sort: sort, Desc: true, CategorySlug: "waffle", MinPrice: &minPrice}
+		c := NewProductCursor(p, f)
+		if c.ID != 3 || !c.Valid(f) {
+			t.Errorf("expected a valid %s cursor, got %+v", sort, c)
+		}
+		if c.Valid(ProductFilter{Sort: sort, CategorySlug: "waffle", MinPrice: &minPrice}) {
+			t.Errorf("expected a %s cursor to be rejected for another order", sort)
+		}
+		if c.Valid(ProductFilter{Sort: sort, Desc: true, CategorySlug: "waffle", MinPrice: &otherMinPrice}) {
+			t.Errorf("expected a %s cursor to be rejected for other filters", sort)
+		}
+		if c.Valid(ProductFilter{Sort: sort, Desc: true, CategorySlug: "cake", MinPrice: &minPrice}) {
+			t.Errorf("expected a %s cursor to be rejected for another category", sort)
+		}
+	}
+	if NewProductCursor(p, ProductFilter{Sort: ProductSortPrice}).Value != "6.50" {
+		t.Error("expected the price as cursor value")
+	}
+	if (ProductCursor{Sort: "id", Value: "1"}).Valid(ProductFilter{Sort: "id"}) {
+		t.Error("expected an unknown sort to be rejected")
+	}
+}
+
+// argEquals matches a query argument, comparing values the driver converted.
+type argEquals struct {
+	want any
+}
+
+func (a argEquals) Match(v driver.Value) bool {
+	switch want := a.want.(type) {
+	case int:
+		n, ok := v.(int64)
+		return ok && n == int64(want)
+	case time.Time:
+		got, ok := v.(time.Time)
+		return ok && got.Equal(want)
+	default:
+		return v == a.want
+	}
+}
+
+func TestProductRepo_Search(t *testing.T) {
+	const (
+		headline        = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+		escapedName     = `REPLACE(REPLACE(REPLACE(REPLACE(name, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+		escapedCategory = `REPLACE(REPLACE(REPLACE(REPLACE(category, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+	)
+	tests := []struct {
+		name   string
+		search ProductSearch
+		sql    string
+		args   []any
+	}{
+		{
+			name:   "prefix match",
+			search: ProductSearch{Query: "choc waf", Limit: 20},
+			sql: `SELECT id AS product_id, search_vector @@ to_tsquery('simple', $1) AS prefix_match, ` +
+				`ts_rank(search_vector, to_tsquery('simple', $2)) AS rank, ` +
+				`ts_headline('simple', ` + escapedName + `, to_tsquery('simple', $3), $4) AS name_highlight, ` +
+				`ts_headline('simple', ` + escapedCategory + `, to_tsquery('simple', $5), $6) AS category_highlight ` +
+				`FROM "products" WHERE search_vector @@ to_tsquery('simple', $7) AND "products"."deleted_at" IS NULL ` +
+				`ORDER BY prefix_match DESC,rank DESC,id LIMIT $8`,
+			args: []any{"choc:* & waf:*", "choc:* & waf:*", "choc:* & waf:*", headline, "choc:* & waf:*", headline, "choc:* & waf:*", 20},
+		},
+		{
+			name:   "fuzzy",
+			search: ProductSearch{Query: "wafle", Fuzzy: true, Limit: 5},
+			sql: `SELECT id AS product_id, search_vector @@ to_tsquery('simple', $1) AS prefix_match, ` +
+				`CASE WHEN search_vector @@ to_tsquery('simple', $2) THEN ts_rank(search_vector, to_tsquery('simple', $3)) ` +
+				`ELSE word_similarity($4, name || ' ' || category) END AS rank, ` +
+				`ts_headline('simple', ` + escapedName + `, to_tsquery('simple', $5), $6) AS name_highlight, ` +
+				`ts_headline('simple', ` + escapedCategory + `, to_tsquery('simple', $7), $8) AS category_highlight ` +
+				`FROM "products" WHERE (search_vector @@ to_tsquery('simple', $9) OR $10 <% (name || ' ' || category)) ` +
+				`AND "products"."deleted_at" IS NULL ORDER BY prefix_match DESC,rank DESC,id LIMIT $11`,
+			args: []any{"wafle:*", "wafle:*", "wafle:*", "wafle", "wafle:*", headline, "wafle:*", headline, "wafle:*", "wafle", 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := testutil.NewMockDB(t)
+			args := make([]driver.Value, len(tt.args))
+			for i, a := range tt.args {
+				args[i] = argEquals{a}
+			}
+			mock.ExpectQuery("^" + regexp.QuoteMeta(tt.sql) + "$").WithArgs(args...).
+				WillReturnRows(sqlmock.NewRows([]string{"product_id", "rank", "name_highlight", "category_highlight"}).
+					AddRow(3, 0.6, "<mark>Chocolate</mark> <mark>Waffle</mark>", "<mark>Waffle</mark>"))
+
+			repo := NewProductRepo(gormDB)
+			matches, err := repo.Search(context.Background(), tt.search)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(matches) != 1 || matches[0].ProductID != 3 || matches[0].NameHighlight == "" {
+				t.Errorf("unexpected matches %+v", matches)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := map[string]string{
+		"Choc":                      "choc:*",
+		"  crème  brûlée ":          "crème:* & brûlée:*",
+		"waffle & !cake | x:*":      "waffle:* & cake:* & x:*",
+		"'); DROP TABLE products":   "drop:* & table:* & products:*",
+		"!!!":                       "",
+		"a b c d e f g h i j k l m": "a:* & b:* & c:* & d:* & e:* & f:* & g:* & h:* & i:* & j:*",
+	}
+	for q, want := range tests {
+		if got := prefixTSQuery(q); got != want {
+			t.Errorf("prefixTSQuery(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestProductRepo_Search_NoWords(t *testing.T) {
+	gormDB, mock := testutil.NewMockDB(t)
+	repo := NewProductRepo(gormDB)
+
+	matches, err := repo.Search(context.Background(), ProductSearch{Query: "%%", Limit: 20})
+	if err != nil || len(matches) != 0 {
+		t.Errorf("expected no matches without a query, got %v %v", matches, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	errors2 "errors"
+
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PromotionRepo struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepo(db *gorm.DB) PromotionRepo {
+	return PromotionRepo{db: db}
+}
+
+// FindByCouponCode returns the active promotion attached to the coupon code with its rules.
+func (r *PromotionRepo) FindByCouponCode(ctx context.Context, code string) (*db.Promotion, error) {
+	var promotion db.Promotion
+	err := r.db.WithContext(ctx).Preload("Rules").
+		First(&promotion, "coupon_code = ? AND active", code).Error
+	if errors2.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.ErrPromotionNotFound
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("error fetching promotion for coupon code %s: %v", code, err)
+		return nil, errors.ErrDatabaseError
+	}
+
+	return &promotion, nil
+}
+
+// CheckRedemptionLimits fails with ErrCouponExhausted when the promotion reached its
+// total or per customer redemption limit. The promotion row is locked until the end of
+// the transaction, so call it through UnitOfWork.Do together with the order insert to
+// stop concurrent orders from redeeming the coupon past its limits.
+func (r *PromotionRepo) CheckRedemptionLimits(ctx context.Context, promotion *db.Promotion, customerID string) error {
+	if promotion.MaxRedemptions == nil && promotion.MaxRedemptionsPerCustomer == nil {
+		return nil
+	}
+
+	tx := r.db.WithContext(ctx)
+	var locked db.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, promotion.ID).Error; err != nil {
+		log.WithCtx(ctx).Error().Msgf("error locking promotion %d: %v", promotion.ID, err)
+		return errors.ErrDatabaseError
+	}
+
+	if promotion.MaxRedemptions != nil {
+		var count int64
+		err := tx.Model(&db.CouponRedemption{}).Where("coupon_code = ?", promotion.CouponCode).Count(&count).Error
+		if err != nil {
+			log.WithCtx(ctx).Error().Msgf("error counting coupon redemptions: %v", err)
+			return errors.ErrDatabaseError
+		}
+		if count >= int64(*promotion.MaxRedemptions) {
+			return errors.ErrCouponExhausted
+		}
+	}
+
+	if promotion.MaxRedemptionsPerCustomer != nil {
+		var count int64
+		err := tx.Model(&db.CouponRedemption{}).
+			Where("coupon_code = ? AND customer_id = ?", promotion.CouponCode, customerID).
+			Count(&count).Error
+		if err != nil {
+			log.WithCtx(ctx).Error().Msgf("error counting customer coupon redemptions: %v", err)
+			return errors.ErrDatabaseError
+		}
+		if count >= int64(*promotion.MaxRedemptionsPerCustomer) {
+			return errors.ErrCouponExhausted
+		}
+	}
+
+	return nil
+}
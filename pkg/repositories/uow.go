@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Repositories are the repositories bound to one database handle. Inside UnitOfWork.Do
+// they all run on the same transaction.
+type Repositories struct {
+	Orders     OrderRepo
+	Products   ProductRepo
+	Promotions PromotionRepo
+	APIKeys    APIKeyRepo
+	Categories CategoryRepo
+}
+
+func newRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Orders:     NewOrderRepo(db),
+		Products:   NewProductRepo(db),
+		Promotions: NewPromotionRepo(db),
+		APIKeys:    NewAPIKeyRepo(db),
+		Categories: NewCategoryRepo(db),
+	}
+}
+
+// UnitOfWork runs several repository operations in one database transaction.
+type UnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return UnitOfWork{db: db}
+}
+
+// Do runs fn in a transaction. The transaction is committed when fn returns nil and
+// rolled back when it returns an error or panics, the error of fn is returned as is.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(newRepositories(tx))
+	})
+}
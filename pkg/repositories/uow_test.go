@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	errors2 "errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/money"
+)
+
+func TestUnitOfWork_Commit(t *testing.T) {
+	gormDB, mock := testutil.NewMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "products" WHERE id IN`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(1, "Waffle", 6.5, "Waffle"))
+	mock.ExpectQuery(`SELECT \* FROM "product_images"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+	mock.ExpectQuery(`INSERT INTO "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("8e8f1b0e-3a9f-4c59-9a52-1c2d3e4f5a6b"))
+	mock.ExpectCommit()
+
+	uow := NewUnitOfWork(gormDB)
+	err := uow.Do(context.Background(), func(repos Repositories) error {
+		if _, err := repos.Products.FindByIDs(context.Background(), []uint{1}); err != nil {
+			return err
+		}
+		return repos.Orders.Create(context.Background(), &db.Order{Total: money.New(650, money.USD)})
+	})
+	if err != nil {
+		t.Fatalf("expected commit, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnitOfWork_RollbackOnPartialFailure(t *testing.T) {
+	maxRedemptions := 10
+	promotion := &db.Promotion{ID: 1, CouponCode: "HAPPYHRS", MaxRedemptions: &maxRedemptions}
+
+	tests := []struct {
+		name        string
+		expect      func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "order insert fails after the redemption check",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT "id" FROM "promotions" .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "coupon_redemptions"`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+				mock.ExpectQuery(`INSERT INTO "orders"`).WillReturnError(errors2.New("connection reset"))
+			},
+			expectedErr: errors.ErrDatabaseError,
+		},
+		{
+			name: "coupon exhausted",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT "id" FROM "promotions" .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "coupon_redemptions"`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+			},
+			expectedErr: errors.ErrCouponExhausted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gormDB, mock := testutil.NewMockDB(t)
+			mock.ExpectBegin()
+			tt.expect(mock)
+			mock.ExpectRollback()
+
+			uow := NewUnitOfWork(gormDB)
+			err := uow.Do(context.Background(), func(repos Repositories) error {
+				if err := repos.Promotions.CheckRedemptionLimits(context.Background(), promotion, "client"); err != nil {
+					return err
+				}
+				return repos.Orders.Create(context.Background(), &db.Order{Total: money.New(650, money.USD)})
+			})
+			if !errors2.Is(err, tt.expectedErr) {
+				t.Errorf("expected %v, got %v", tt.expectedErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
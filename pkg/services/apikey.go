@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	errors2 "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+	"github.com/malakagl/kart-challenge/pkg/util"
+)
+
+const (
+	apiKeyPrefix           = "kart_"
+	apiKeyPrefixLength     = 12 // characters of the key kept to recognise it in listings
+	defaultRotationOverlap = 24 * time.Hour
+	maxRotationOverlap     = 30 * 24 * time.Hour
+)
+
+// Actions recorded in the api_key_audit table
+const (
+	APIKeyCreated = "created"
+	APIKeyRotated = "rotated"
+	APIKeyRevoked = "revoked"
+)
+
+type IAPIKeyService interface {
+	Create(ctx context.Context, req *request.APIKeyRequest) (*response.APIKey, error)
+	FindAll(ctx context.Context) (*response.APIKeysResponse, error)
+	Rotate(ctx context.Context, id string, req *request.APIKeyRotateRequest) (*response.APIKeyRotation, error)
+	Revoke(ctx context.Context, id string) (*response.APIKey, error)
+}
+
+// KeyCache drops cached API key lookups, auth.APIKeyAuthenticator implements it.
+type KeyCache interface {
+	Invalidate(hash string)
+}
+
+type APIKeyService struct {
+	uow        repositories.UnitOfWork
+	apiKeyRepo repositories.APIKeyRepo
+	cache      KeyCache
+}
+
+func NewAPIKeyService(u repositories.UnitOfWork, r repositories.APIKeyRepo, c KeyCache) APIKeyService {
+	return APIKeyService{
+		uow:        u,
+		apiKeyRepo: r,
+		cache:      c,
+	}
+}
+
+// generateAPIKey returns a new random key, only its hash is stored.
+func generateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newAPIKey(name string, scopes []string, expiresAt *time.Time) (*db.APIKey, string, error) {
+	plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &db.APIKey{
+		ID:        uuid.New(),
+		Name:      name,
+		KeyHash:   auth.HashKey(plaintext),
+		Prefix:    plaintext[:apiKeyPrefixLength],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	}, plaintext, nil
+}
+
+// rotationOverlap parses how long a rotated key keeps working, 24h when not given.
+func rotationOverlap(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultRotationOverlap, nil
+	}
+
+	overlap, err := time.ParseDuration(s)
+	if err != nil || overlap < 0 || overlap > maxRotationOverlap {
+		return 0, errors.ErrInvalidRotationOverlap
+	}
+
+	return overlap, nil
+}
+
+// rotatedExpiry is the new expiry of a rotated key, it never extends the current expiry.
+func rotatedExpiry(current *time.Time, now time.Time, overlap time.Duration) time.Time {
+	expiry := now.Add(overlap)
+	if current != nil && current.Before(expiry) {
+		return *current
+	}
+
+	return expiry
+}
+
+func toAPIKeyResponse(key *db.APIKey) response.APIKey {
+	res := response.APIKey{
+		ID:         key.ID.String(),
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+	if key.ReplacedBy != nil {
+		res.ReplacedBy = key.ReplacedBy.String()
+	}
+
+	return res
+}
+
+// Create issues a new API key. The plaintext key is only part of this response.
+func (s *APIKeyService) Create(ctx context.Context, req *request.APIKeyRequest) (*response.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Create")
+	defer span.End()
+
+	key, plaintext, err := newAPIKey(req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error generating api key: %v", err)
+		return nil, errors.ErrInternalServerError
+	}
+
+	err = s.uow.Do(ctx, func(repos repositories.Repositories) error {
+		if err := repos.APIKeys.Create(ctx, key); err != nil {
+			return err
+		}
+
+		return repos.APIKeys.Audit(ctx, &db.APIKeyAudit{
+			APIKeyID: key.ID,
+			Action:   APIKeyCreated,
+			ActorID:  util.ClientID(ctx),
+			Details:  "scopes: " + key.Scopes,
+		})
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating api key: %v", err)
+		return nil, errors.ErrInternalServerError
+	}
+
+	res := toAPIKeyResponse(key)
+	res.Key = plaintext
+	return &res, nil
+}
+
+func (s *APIKeyService) FindAll(ctx context.Context) (*response.APIKeysResponse, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.FindAll")
+	defer span.End()
+
+	keys, err := s.apiKeyRepo.FindAll(ctx)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error listing api keys: %v", err)
+		return nil, err
+	}
+
+	res := &response.APIKeysResponse{APIKeys: make([]response.APIKey, len(keys))}
+	for i := range keys {
+		res.APIKeys[i] = toAPIKeyResponse(&keys[i])
+	}
+
+	return res, nil
+}
+
+// Rotate issues a new key with the same name, scopes and expiry. The old key keeps
+// working for the overlap so clients can switch without downtime.
+func (s *APIKeyService) Rotate(ctx context.Context, id string, req *request.APIKeyRotateRequest) (*response.APIKeyRotation, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Rotate")
+	defer span.End()
+
+	keyID, err := uuid.Parse(id)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid api key ID %s: %v", id, err)
+		return nil, errors.ErrInvalidAPIKeyID
+	}
+
+	overlap, err := rotationOverlap(req.Overlap)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid rotation overlap %s", req.Overlap)
+		return nil, err
+	}
+
+	var old, key *db.APIKey
+	var plaintext string
+	err = s.uow.Do(ctx, func(repos repositories.Repositories) error {
+		old, err = repos.APIKeys.FindByIDForUpdate(ctx, keyID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case old.RevokedAt != nil:
+			return errors.ErrAPIKeyRevoked
+		case old.ReplacedBy != nil:
+			return errors.ErrAPIKeyRotated
+		case old.ExpiresAt != nil && !now.Before(*old.ExpiresAt):
+			return errors.ErrAPIKeyExpired
+		}
+
+		key, plaintext, err = newAPIKey(old.Name, old.ScopeList(), old.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if err := repos.APIKeys.Create(ctx, key); err != nil {
+			return err
+		}
+
+		expiry := rotatedExpiry(old.ExpiresAt, now, overlap)
+		old.ExpiresAt = &expiry
+		old.ReplacedBy = &key.ID
+		if err := repos.APIKeys.Save(ctx, old); err != nil {
+			return err
+		}
+
+		if err := repos.APIKeys.Audit(ctx, &db.APIKeyAudit{
+			APIKeyID: old.ID,
+			Action:   APIKeyRotated,
+			ActorID:  util.ClientID(ctx),
+			Details:  fmt.Sprintf("replaced by %s, expires at %s", key.ID, expiry.Format(time.RFC3339)),
+		}); err != nil {
+			return err
+		}
+
+		return repos.APIKeys.Audit(ctx, &db.APIKeyAudit{
+			APIKeyID: key.ID,
+			Action:   APIKeyCreated,
+			ActorID:  util.ClientID(ctx),
+			Details:  "rotation of " + old.ID.String(),
+		})
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error rotating api key %s: %v", id, err)
+		return nil, apiKeyError(err)
+	}
+
+	// the cached lookup still has the old expiry
+	s.cache.Invalidate(old.KeyHash)
+
+	res := &response.APIKeyRotation{New: toAPIKeyResponse(key), Old: toAPIKeyResponse(old)}
+	res.New.Key = plaintext
+	return res, nil
+}
+
+// Revoke stops the key from working immediately. Revoking a revoked key changes nothing.
+func (s *APIKeyService) Revoke(ctx context.Context, id string) (*response.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Revoke")
+	defer span.End()
+
+	keyID, err := uuid.Parse(id)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid api key ID %s: %v", id, err)
+		return nil, errors.ErrInvalidAPIKeyID
+	}
+
+	var key *db.APIKey
+	err = s.uow.Do(ctx, func(repos repositories.Repositories) error {
+		key, err = repos.APIKeys.FindByIDForUpdate(ctx, keyID)
+		if err != nil || key.RevokedAt != nil {
+			return err
+		}
+
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := repos.APIKeys.Save(ctx, key); err != nil {
+			return err
+		}
+
+		return repos.APIKeys.Audit(ctx, &db.APIKeyAudit{
+			APIKeyID: key.ID,
+			Action:   APIKeyRevoked,
+			ActorID:  util.ClientID(ctx),
+		})
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error revoking api key %s: %v", id, err)
+		return nil, apiKeyError(err)
+	}
+
+	s.cache.Invalidate(key.KeyHash)
+
+	res := toAPIKeyResponse(key)
+	return &res, nil
+}
+
+// apiKeyError passes the errors the handler reports on and hides the others.
+func apiKeyError(err error) error {
+	for _, known := range []error{
+		errors.ErrAPIKeyNotFound,
+		errors.ErrAPIKeyRevoked,
+		errors.ErrAPIKeyRotated,
+		errors.ErrAPIKeyExpired,
+	} {
+		if errors2.Is(err, known) {
+			return err
+		}
+	}
+
+	return errors.ErrInternalServerError
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	errors2 "errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/internal/auth"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+)
+
+// recordingKeyCache implements KeyCache for testing
+type recordingKeyCache []string
+
+func (c *recordingKeyCache) Invalidate(hash string) {
+	*c = append(*c, hash)
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, plaintext, err := newAPIKey("pos", []string{"create_order", "read_orders"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) || len(plaintext) != len(apiKeyPrefix)+43 {
+		t.Errorf("unexpected key format %q", plaintext)
+	}
+	if key.KeyHash != auth.HashKey(plaintext) || strings.Contains(key.KeyHash, plaintext) {
+		t.Error("expected only the hash of the key to be stored")
+	}
+	if key.Prefix != plaintext[:apiKeyPrefixLength] || key.Scopes != "create_order read_orders" {
+		t.Errorf("unexpected key %+v", key)
+	}
+
+	_, other, _ := newAPIKey("pos", nil, nil)
+	if other == plaintext {
+		t.Error("expected keys to be random")
+	}
+}
+
+func TestRotationOverlap(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{in: "", expected: 24 * time.Hour},
+		{in: "90m", expected: 90 * time.Minute},
+		{in: "0s", expected: 0},
+		{in: "-1h", wantErr: true},
+		{in: "9999h", wantErr: true},
+		{in: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := rotationOverlap(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRotatedExpiry(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	soon, later := now.Add(time.Hour), now.Add(48*time.Hour)
+
+	if got := rotatedExpiry(nil, now, 24*time.Hour); !got.Equal(now.Add(24 * time.Hour)) {
+		t.Errorf("expected the overlap, got %s", got)
+	}
+	if got := rotatedExpiry(&later, now, 24*time.Hour); !got.Equal(now.Add(24 * time.Hour)) {
+		t.Errorf("expected the overlap, got %s", got)
+	}
+	if got := rotatedExpiry(&soon, now, 24*time.Hour); !got.Equal(soon) {
+		t.Errorf("expected the current expiry not to be extended, got %s", got)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	gormDB, sqlMock := testutil.NewMockDB(t)
+
+	id := uuid.New()
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "api_keys" WHERE id = $1`) + `.* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key_hash", "scopes"}).AddRow(id, "pos", "hash", "create_order"))
+	sqlMock.ExpectExec(`UPDATE "api_keys" SET .*"revoked_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectQuery(`INSERT INTO "api_key_audit"`).WithArgs(id, APIKeyRevoked, sqlmock.AnyArg(), "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	cache := &recordingKeyCache{}
+	s := NewAPIKeyService(repositories.NewUnitOfWork(gormDB), repositories.NewAPIKeyRepo(gormDB), cache)
+	res, err := s.Revoke(t.Context(), id.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.RevokedAt == nil {
+		t.Error("expected the key to be revoked")
+	}
+	if len(*cache) != 1 || (*cache)[0] != "hash" {
+		t.Errorf("expected the cached lookup to be invalidated, got %v", *cache)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := s.Revoke(t.Context(), "not-a-uuid"); !errors2.Is(err, errors.ErrInvalidAPIKeyID) {
+		t.Errorf("expected invalid api key ID, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	errors2 "errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+)
+
+type ICategoryService interface {
+	FindAll(ctx context.Context) (*response.CategoriesResponse, error)
+	Create(ctx context.Context, req *request.CategoryRequest) (*response.Category, error)
+}
+
+type CategoryService struct {
+	repo repositories.CategoryRepo
+}
+
+func NewCategoryService(r repositories.CategoryRepo) CategoryService {
+	return CategoryService{repo: r}
+}
+
+// FindAll lists the active categories in the order the menu shows them.
+func (s *CategoryService) FindAll(ctx context.Context) (*response.CategoriesResponse, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.FindAll")
+	defer span.End()
+
+	res, err := s.repo.FindActive(ctx)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error listing categories: %v", err)
+		return nil, err
+	}
+
+	categories := make([]response.Category, len(res))
+	for i := range res {
+		categories[i] = toCategoryResponse(&res[i])
+	}
+
+	return &response.CategoriesResponse{Categories: categories}, nil
+}
+
+// Create adds an active category, its slug is derived from the name. A name differing
+// from an existing one only in case or punctuation is rejected.
+func (s *CategoryService) Create(ctx context.Context, req *request.CategoryRequest) (*response.Category, error) {
+	ctx, span := tracing.Start(ctx, "CategoryService.Create")
+	defer span.End()
+
+	slug := categorySlug(req.Name)
+	if slug == "" {
+		return nil, errors.ErrInvalidCategoryName
+	}
+
+	_, err := s.repo.FindBySlug(ctx, slug)
+	if err == nil {
+		return nil, errors.ErrCategoryExists
+	}
+	if !errors2.Is(err, errors.ErrCategoryNotFound) {
+		log.WithCtx(ctx).Error().Msgf("Error fetching category %s: %v", slug, err)
+		return nil, err
+	}
+
+	category := db.Category{Name: req.Name, Slug: slug, DisplayOrder: req.DisplayOrder, Active: true}
+	if err := s.repo.Create(ctx, &category); err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating category: %v", err)
+		return nil, err
+	}
+
+	res := toCategoryResponse(&category)
+	return &res, nil
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// categorySlug derives the slug of a category name the same way migration 000011 did:
+// lower case, runs of other characters than letters and digits replaced by a dash.
+func categorySlug(name string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func toCategoryResponse(c *db.Category) response.Category {
+	return response.Category{
+		ID:           strconv.FormatUint(uint64(c.ID), 10),
+		Name:         c.Name,
+		Slug:         c.Slug,
+		DisplayOrder: c.DisplayOrder,
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	errors2 "errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+)
+
+func newCategoryService(t *testing.T) (CategoryService, sqlmock.Sqlmock) {
+	t.Helper()
+	gormDB, sqlMock := testutil.NewMockDB(t)
+
+	return NewCategoryService(repositories.NewCategoryRepo(gormDB)), sqlMock
+}
+
+func TestCategorySlug(t *testing.T) {
+	tests := map[string]string{
+		"Waffle":        "waffle",
+		" Crème Brûlée": "cr-me-br-l-e",
+		"Pies & Tarts!": "pies-tarts",
+		"???":           "",
+	}
+	for name, slug := range tests {
+		if got := categorySlug(name); got != slug {
+			t.Errorf("categorySlug(%q) = %q, expected %q", name, got, slug)
+		}
+	}
+}
+
+func TestCategoryCreate(t *testing.T) {
+	s, sqlMock := newCategoryService(t)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE slug = $1`)).WithArgs("pies-tarts", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`INSERT INTO "categories"`).WithArgs("Pies & Tarts", "pies-tarts", 3, true, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	sqlMock.ExpectCommit()
+
+	res, err := s.Create(t.Context(), &request.CategoryRequest{Name: "Pies & Tarts", DisplayOrder: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ID != "5" || res.Slug != "pies-tarts" || res.DisplayOrder != 3 {
+		t.Errorf("unexpected category %+v", res)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCategoryCreate_RejectsDuplicateSlug(t *testing.T) {
+	s, sqlMock := newCategoryService(t)
+	sqlMock.ExpectQuery(`SELECT \* FROM "categories"`).WithArgs("waffle", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug"}).AddRow(4, "Waffle", "waffle"))
+
+	_, err := s.Create(t.Context(), &request.CategoryRequest{Name: "WAFFLE"})
+	if !errors2.Is(err, errors.ErrCategoryExists) {
+		t.Errorf("expected category exists, got %v", err)
+	}
+}
+
+func TestCategoryCreate_RejectsNameWithoutSlug(t *testing.T) {
+	s, _ := newCategoryService(t)
+
+	_, err := s.Create(t.Context(), &request.CategoryRequest{Name: "!!!"})
+	if !errors2.Is(err, errors.ErrInvalidCategoryName) {
+		t.Errorf("expected invalid category name, got %v", err)
+	}
+}
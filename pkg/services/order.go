@@ -2,37 +2,76 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	errors2 "errors"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/malakagl/kart-challenge/internal/couponcode"
+	"github.com/malakagl/kart-challenge/internal/metrics"
+	"github.com/malakagl/kart-challenge/internal/tracing"
+	"github.com/malakagl/kart-challenge/pkg/constants"
 	"github.com/malakagl/kart-challenge/pkg/errors"
 	"github.com/malakagl/kart-challenge/pkg/log"
 	"github.com/malakagl/kart-challenge/pkg/models/db"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/money"
+	"github.com/malakagl/kart-challenge/pkg/promotions"
 	"github.com/malakagl/kart-challenge/pkg/repositories"
 	"github.com/malakagl/kart-challenge/pkg/util"
 )
 
+const defaultOrderPageSize = 20
+
 type IOrderService interface {
 	Create(ctx context.Context, req *request.OrderRequest) (*response.OrderResponse, error)
+	FindByID(ctx context.Context, id string) (*response.OrderResponse, error)
+	FindAll(ctx context.Context, req *request.OrderListRequest) (*response.OrdersResponse, *response.Pagination, error)
+	UpdateStatus(ctx context.Context, id string, req *request.OrderStatusRequest) (*response.OrderResponse, error)
 }
 
 type OrderService struct {
-	orderRepo      repositories.OrderRepo
-	couponCodeRepo repositories.CouponCodeRepo
-	productRepo    repositories.ProductRepo
+	uow             repositories.UnitOfWork
+	couponValidator couponcode.CouponValidator
+	orderRepo       repositories.OrderRepo
+	productRepo     repositories.ProductRepo
 }
 
 func NewOrderService(
+	u repositories.UnitOfWork,
+	v couponcode.CouponValidator,
 	r repositories.OrderRepo,
-	c repositories.CouponCodeRepo,
 	p repositories.ProductRepo,
 ) OrderService {
 	return OrderService{
-		orderRepo:      r,
-		couponCodeRepo: c,
-		productRepo:    p,
+		uow:             u,
+		couponValidator: v,
+		orderRepo:       r,
+		productRepo:     p,
+	}
+}
+
+// orderRejections are the errors Create returns to the caller as they are, any other
+// error of the order transaction is reported as an internal server error.
+var orderRejections = []error{
+	errors.ErrProductNotFound,
+	errors.ErrCouponNotYetValid,
+	errors.ErrCouponExpired,
+	errors.ErrCouponBelowMinimum,
+	errors.ErrCouponExhausted,
+}
+
+// customerID identifies the customer of the request for coupon limits. Bearer tokens name
+// the customer, API key clients are their own customer.
+func customerID(ctx context.Context) string {
+	if id, ok := ctx.Value(constants.CustomerIDKey).(string); ok && id != "" {
+		return id
 	}
+
+	return util.ClientID(ctx)
 }
 
 func (o *OrderService) isCouponCodeValid(ctx context.Context, code string) (bool, error) {
@@ -40,65 +79,159 @@ func (o *OrderService) isCouponCodeValid(ctx context.Context, code string) (bool
 		return false, nil
 	}
 
-	if couponcode.ValidateCouponCode(ctx, code) {
-		return true, nil
+	return o.couponValidator.Validate(ctx, code)
+}
+
+// findPromotion loads the active promotion attached to the coupon code. A coupon code
+// without a promotion is valid but grants no discount and has no limits.
+func findPromotion(ctx context.Context, repo repositories.PromotionRepo, code string) (*db.Promotion, error) {
+	promotion, err := repo.FindByCouponCode(ctx, code)
+	if errors2.Is(err, errors.ErrPromotionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching promotion: %v", err)
+		return nil, errors.ErrInternalServerError
 	}
-	// use database
-	// count, errors := o.couponCodeRepo.CountFilesByCode(code)
-	// if count > 1 {
-	// 	log.WithCtx(ctx).Error().Msgf("Coupon code %s is valid: found in multiple files", code)
-	// 	return true, nil
-	// }
 
-	return false, nil
+	return promotion, nil
 }
 
+// checkCouponPolicy enforces the validity window and minimum order value of a coupon.
+// Redemption limits are enforced by PromotionRepo.CheckRedemptionLimits inside the order
+// transaction.
+func checkCouponPolicy(promotion *db.Promotion, subtotal money.Money, now time.Time) error {
+	if promotion == nil {
+		return nil
+	}
+
+	if promotion.ValidFrom != nil && now.Before(*promotion.ValidFrom) {
+		return errors.ErrCouponNotYetValid
+	}
+	if promotion.ValidTo != nil && !now.Before(*promotion.ValidTo) {
+		return errors.ErrCouponExpired
+	}
+	belowMinimum, err := subtotal.Cmp(promotion.MinOrderValue)
+	if err != nil {
+		return err
+	}
+	if belowMinimum < 0 {
+		return errors.ErrCouponBelowMinimum
+	}
+
+	return nil
+}
+
+func promotionRules(promotion *db.Promotion) []promotions.Rule {
+	if promotion == nil {
+		return nil
+	}
+
+	rules := make([]promotions.Rule, len(promotion.Rules))
+	for i, r := range promotion.Rules {
+		rules[i] = promotions.Rule{
+			Type:         promotions.RuleType(r.Type),
+			Percentage:   r.Percentage,
+			Amount:       r.Amount,
+			BuyQuantity:  r.BuyQuantity,
+			FreeQuantity: r.FreeQuantity,
+			MinQuantity:  r.MinQuantity,
+			Category:     r.Category,
+		}
+		if r.ProductID != nil {
+			rules[i].ProductID = *r.ProductID
+		}
+	}
+
+	return rules
+}
+
+// Create places the order. Reading the product prices, checking the coupon redemption
+// limits and inserting the order happen in one transaction.
 func (o *OrderService) Create(ctx context.Context, req *request.OrderRequest) (*response.OrderResponse, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.Create")
+	defer span.End()
+
 	couponCodeIsValid, err := o.isCouponCodeValid(ctx, req.CouponCode)
 	if err != nil {
 		return nil, err
 	}
 
-	if !couponCodeIsValid { // !h.couponValidator.ValidateCouponCode(orderReq.CouponCode)
+	if !couponCodeIsValid {
 		log.WithCtx(ctx).Error().Msgf("Invalid coupon code: %s", req.CouponCode)
 		return nil, errors.ErrInvalidCouponCode
 	}
 
-	order := db.Order{}
-	orderProducts := make([]*db.OrderProduct, len(req.Items))
-	products := make([]response.Product, len(req.Items))
-	items := make([]response.Item, len(req.Items))
+	productIDs := make([]uint, len(req.Items))
 	for i, item := range req.Items {
 		productId, err := util.StringToUint(item.ProductID)
 		if err != nil || productId == 0 {
 			log.WithCtx(ctx).Error().Msg("Invalid product ID in order request")
 			return nil, errors.ErrInvalidProductID
 		}
+		productIDs[i] = productId
+	}
 
-		product, err := o.productRepo.FindByID(ctx, productId)
-		if err != nil && err.Error() == "not found" {
-			log.WithCtx(ctx).Error().Msgf("Error fetching product: %v", err)
-			return nil, errors.ErrProductNotFound
+	var res *response.OrderResponse
+	err = o.uow.Do(ctx, func(repos repositories.Repositories) error {
+		res, err = createOrder(ctx, repos, req, productIDs)
+		return err
+	})
+	for _, rejection := range orderRejections {
+		if errors2.Is(err, rejection) {
+			log.WithCtx(ctx).Error().Msgf("Order rejected: %v", err)
+			return nil, err
 		}
-		if err != nil {
-			log.WithCtx(ctx).Error().Msgf("Error fetching product: %v", err)
-			return nil, errors.ErrInternalServerError
+	}
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating order: %v", err)
+		return nil, errors.ErrInternalServerError
+	}
+
+	currency := string(res.Total.Currency())
+	metrics.OrdersCreated.WithLabelValues(currency).Inc()
+	if res.Total.IsPositive() { // counters panic on negative values
+		metrics.OrderRevenue.WithLabelValues(currency).Add(res.Total.Float64())
+	}
+	return res, nil
+}
+
+func createOrder(ctx context.Context, repos repositories.Repositories, req *request.OrderRequest, productIDs []uint) (*response.OrderResponse, error) {
+	found, err := repos.Products.FindByIDs(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	productsByID := make(map[uint]*db.Product, len(found))
+	for i := range found {
+		productsByID[found[i].ID] = &found[i]
+	}
+
+	order := db.Order{CouponCode: req.CouponCode}
+	lines := make([]promotions.Line, len(req.Items))
+	orderProducts := make([]*db.OrderProduct, len(req.Items))
+	products := make([]response.Product, len(req.Items))
+	items := make([]response.Item, len(req.Items))
+	for i, item := range req.Items {
+		product, ok := productsByID[productIDs[i]]
+		if !ok {
+			log.WithCtx(ctx).Error().Msgf("Product %d not found", productIDs[i])
+			return nil, errors.ErrProductNotFound
 		}
 
-		orderProducts[i] = &db.OrderProduct{ProductID: item.ProductID, Quantity: item.Quantity}
-		order.Total += product.Price * float64(item.Quantity)
-		products[i] = response.Product{
-			ID:       item.ProductID,
-			Name:     product.Name,
-			Price:    product.Price,
-			Category: product.Category,
-			Image: response.ProductImage{
-				Thumbnail: product.Image.Thumbnail,
-				Mobile:    product.Image.Mobile,
-				Tablet:    product.Image.Tablet,
-				Desktop:   product.Image.Desktop,
-			},
+		orderProducts[i] = &db.OrderProduct{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			Name:      product.Name,
+			UnitPrice: product.Price,
 		}
+		lines[i] = promotions.Line{
+			ProductID: productIDs[i],
+			Category:  product.Category,
+			UnitPrice: product.Price,
+			Quantity:  item.Quantity,
+		}
+		products[i] = toProductResponse(product)
 		items[i] = response.Item{
 			ProductID: item.ProductID,
 			Quantity:  item.Quantity,
@@ -106,17 +239,237 @@ func (o *OrderService) Create(ctx context.Context, req *request.OrderRequest) (*
 	}
 	order.Products = orderProducts
 
-	err = o.orderRepo.Create(ctx, &order)
+	promotion, err := findPromotion(ctx, repos.Promotions, req.CouponCode)
 	if err != nil {
-		log.WithCtx(ctx).Error().Msgf("Error creating order: %v", err)
-		return nil, errors.ErrInternalServerError
+		return nil, err
+	}
+
+	result, err := promotions.Apply(lines, promotionRules(promotion))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCouponPolicy(promotion, result.Subtotal, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// only coupons with a promotion have limits, uses of other coupons are not recorded
+	if promotion != nil {
+		if err := repos.Promotions.CheckRedemptionLimits(ctx, promotion, customerID(ctx)); err != nil {
+			return nil, err
+		}
+		order.Redemption = &db.CouponRedemption{CouponCode: req.CouponCode, CustomerID: customerID(ctx)}
+	}
+
+	order.Total = result.Total
+	order.Discounts = result.Discount
+	order.Status = OrderStatusPending
+	order.History = []*db.OrderStatusHistory{{ToStatus: OrderStatusPending, ChangedBy: util.ClientID(ctx)}}
+	if err := repos.Orders.Create(ctx, &order); err != nil {
+		return nil, err
 	}
 
 	return &response.OrderResponse{
-		ID:        order.ID.String(),
-		Total:     order.Total,
-		Discounts: order.Discounts,
-		Items:     items,
-		Products:  products,
+		ID:         order.ID.String(),
+		Total:      order.Total,
+		Discounts:  order.Discounts,
+		CouponCode: order.CouponCode,
+		Status:     order.Status,
+		Items:      items,
+		Products:   products,
+		CreatedAt:  order.CreatedAt,
 	}, nil
 }
+
+func (o *OrderService) FindByID(ctx context.Context, id string) (*response.OrderResponse, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.FindByID")
+	defer span.End()
+
+	orderID, err := uuid.Parse(id)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid order ID %s: %v", id, err)
+		return nil, errors.ErrInvalidOrderID
+	}
+
+	order, err := o.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching order %s: %v", id, err)
+		return nil, err
+	}
+
+	orders, err := o.toOrderResponses(ctx, []db.Order{*order})
+	if err != nil {
+		return nil, err
+	}
+
+	return &orders[0], nil
+}
+
+func (o *OrderService) FindAll(ctx context.Context, req *request.OrderListRequest) (*response.OrdersResponse, *response.Pagination, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.FindAll")
+	defer span.End()
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultOrderPageSize
+	}
+
+	filter := repositories.OrderFilter{
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		CouponCode:  req.CouponCode,
+		MinTotal:    req.MinTotal,
+		MaxTotal:    req.MaxTotal,
+		Limit:       limit + 1, // one extra row tells whether there is a next page
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeOrderCursor(req.Cursor)
+		if err != nil {
+			log.WithCtx(ctx).Error().Msgf("Invalid order cursor %s: %v", req.Cursor, err)
+			return nil, nil, errors.ErrInvalidCursor
+		}
+		filter.After = cursor
+	}
+
+	res, err := o.orderRepo.FindAll(ctx, filter)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error listing orders: %v", err)
+		return nil, nil, err
+	}
+
+	page := &response.Pagination{Limit: limit}
+	if len(res) > limit {
+		res = res[:limit]
+		last := res[limit-1]
+		page.HasMore = true
+		page.NextCursor = encodeOrderCursor(repositories.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	orders, err := o.toOrderResponses(ctx, res)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &response.OrdersResponse{Orders: orders}, page, nil
+}
+
+// UpdateStatus moves the order to the requested status if the transition table allows it.
+func (o *OrderService) UpdateStatus(ctx context.Context, id string, req *request.OrderStatusRequest) (*response.OrderResponse, error) {
+	ctx, span := tracing.Start(ctx, "OrderService.UpdateStatus")
+	defer span.End()
+
+	orderID, err := uuid.Parse(id)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Invalid order ID %s: %v", id, err)
+		return nil, errors.ErrInvalidOrderID
+	}
+
+	order, err := o.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching order %s: %v", id, err)
+		return nil, err
+	}
+
+	if !canTransition(order.Status, req.Status) {
+		log.WithCtx(ctx).Error().Msgf("Order %s cannot move from %s to %s", id, order.Status, req.Status)
+		return nil, errors.ErrInvalidStatusTransition
+	}
+
+	err = o.orderRepo.UpdateStatus(ctx, &db.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: order.Status,
+		ToStatus:   req.Status,
+		ChangedBy:  util.ClientID(ctx),
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error updating order %s status: %v", id, err)
+		return nil, err
+	}
+
+	return o.FindByID(ctx, id)
+}
+
+// toOrderResponses maps stored orders to responses, loading all their products in one query.
+// Products are shown with the name and price they were ordered at. A product that no longer
+// exists, e.g. deleted by hand before products were soft deleted, is shown from that
+// snapshot alone, without category and images.
+func (o *OrderService) toOrderResponses(ctx context.Context, orders []db.Order) ([]response.OrderResponse, error) {
+	var ids []uint
+	for _, order := range orders {
+		for _, p := range order.Products {
+			if id, err := util.StringToUint(p.ProductID); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	products := make(map[string]*db.Product, len(ids))
+	if len(ids) > 0 {
+		res, err := o.productRepo.FindByIDsUnscoped(ctx, ids)
+		if err != nil {
+			log.WithCtx(ctx).Error().Msgf("Error fetching order products: %v", err)
+			return nil, errors.ErrInternalServerError
+		}
+		for i := range res {
+			products[strconv.FormatUint(uint64(res[i].ID), 10)] = &res[i]
+		}
+	}
+
+	responses := make([]response.OrderResponse, len(orders))
+	for i, order := range orders {
+		items := make([]response.Item, len(order.Products))
+		orderProducts := make([]response.Product, 0, len(order.Products))
+		for j, p := range order.Products {
+			items[j] = response.Item{ProductID: p.ProductID, Quantity: p.Quantity}
+			ordered := response.Product{ID: p.ProductID}
+			if product, ok := products[p.ProductID]; ok {
+				ordered = toProductResponse(product)
+			} else {
+				log.WithCtx(ctx).Warn().Msgf("Product %s of order %s not found, showing the ordered snapshot", p.ProductID, order.ID)
+			}
+			ordered.Name, ordered.Price = p.Name, p.UnitPrice
+			orderProducts = append(orderProducts, ordered)
+		}
+
+		responses[i] = response.OrderResponse{
+			ID:         order.ID.String(),
+			Total:      order.Total,
+			Discounts:  order.Discounts,
+			CouponCode: order.CouponCode,
+			Status:     order.Status,
+			Items:      items,
+			Products:   orderProducts,
+			CreatedAt:  order.CreatedAt,
+		}
+		for _, h := range order.History {
+			responses[i].StatusHistory = append(responses[i].StatusHistory, response.StatusChange{
+				From:      h.FromStatus,
+				To:        h.ToStatus,
+				ChangedBy: h.ChangedBy,
+				Reason:    h.Reason,
+				ChangedAt: h.CreatedAt,
+			})
+		}
+	}
+
+	return responses, nil
+}
+
+func encodeOrderCursor(c repositories.OrderCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeOrderCursor(s string) (*repositories.OrderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var c repositories.OrderCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
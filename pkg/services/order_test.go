@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	errors2 "errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/money"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockCouponValidator implements couponcode.CouponValidator for testing
+type MockCouponValidator struct {
+	mock.Mock
+}
+
+func (m *MockCouponValidator) Validate(_ context.Context, code string) (bool, error) {
+	args := m.Called(code)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestIsCouponCodeValid(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		mockValid bool
+		mockErr   error
+		expected  bool
+		wantErr   bool
+	}{
+		{name: "valid code", code: "HAPPYHRS", mockValid: true, expected: true},
+		{name: "unknown code", code: "NOTFOUND"},
+		{name: "too short", code: "SHORT", mockValid: true},
+		{name: "too long", code: "WAYTOOLONGCODE", mockValid: true},
+		{name: "validator error", code: "HAPPYHRS", mockErr: errors2.New("db error"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := new(MockCouponValidator)
+			validator.On("Validate", tt.code).Return(tt.mockValid, tt.mockErr)
+			s := OrderService{couponValidator: validator}
+
+			ok, err := s.isCouponCodeValid(t.Context(), tt.code)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestCheckCouponPolicy(t *testing.T) {
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	tests := []struct {
+		name      string
+		promotion *db.Promotion
+		subtotal  money.Money
+		expected  error
+	}{
+		{name: "no promotion", subtotal: money.New(1000, money.USD)},
+		{name: "no restrictions", promotion: &db.Promotion{}, subtotal: money.New(1000, money.USD)},
+		{name: "inside validity window", promotion: &db.Promotion{ValidFrom: &before, ValidTo: &after}, subtotal: money.New(1000, money.USD)},
+		{name: "not yet valid", promotion: &db.Promotion{ValidFrom: &after}, expected: errors.ErrCouponNotYetValid},
+		{name: "expired", promotion: &db.Promotion{ValidTo: &before}, expected: errors.ErrCouponExpired},
+		{name: "expires now", promotion: &db.Promotion{ValidTo: &now}, expected: errors.ErrCouponExpired},
+		{name: "minimum reached", promotion: &db.Promotion{MinOrderValue: money.New(2000, money.USD)}, subtotal: money.New(2000, money.USD)},
+		{name: "below minimum", promotion: &db.Promotion{MinOrderValue: money.New(2000, money.USD)}, subtotal: money.New(1999, money.USD), expected: errors.ErrCouponBelowMinimum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkCouponPolicy(tt.promotion, tt.subtotal, now); !errors2.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestOrderCursor(t *testing.T) {
+	cursor := repositories.OrderCursor{
+		CreatedAt: time.Date(2025, 8, 1, 12, 30, 0, 123, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	decoded, err := decodeOrderCursor(encodeOrderCursor(cursor))
+	if err != nil {
+		t.Fatalf("decodeOrderCursor failed: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("expected %+v, got %+v", cursor, decoded)
+	}
+
+	if _, err := decodeOrderCursor("not a cursor"); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+}
+
+func TestCreate_RollsBackWhenProductIsMissing(t *testing.T) {
+	gormDB, sqlMock := testutil.NewMockDB(t)
+
+	// both products are read in one query, product 2 does not exist
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT \* FROM "products" WHERE id IN \(\$1,\$2\)`).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(1, "Waffle", 6.5, "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+	sqlMock.ExpectRollback()
+
+	validator := new(MockCouponValidator)
+	validator.On("Validate", "HAPPYHRS").Return(true, nil)
+	s := NewOrderService(repositories.NewUnitOfWork(gormDB), validator,
+		repositories.NewOrderRepo(gormDB), repositories.NewProductRepo(gormDB))
+
+	_, err := s.Create(t.Context(), &request.OrderRequest{
+		CouponCode: "HAPPYHRS",
+		Items:      []request.Item{{ProductID: "1", Quantity: 1}, {ProductID: "2", Quantity: 1}},
+	})
+	if !errors2.Is(err, errors.ErrProductNotFound) {
+		t.Errorf("expected %v, got %v", errors.ErrProductNotFound, err)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestToOrderResponses_RendersSnapshotOfMissingProduct(t *testing.T) {
+	gormDB, sqlMock := testutil.NewMockDB(t)
+	sqlMock.ExpectQuery(`SELECT \* FROM "products" WHERE id IN \(\$1,\$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(1, "Waffle", 6.5, "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	s := OrderService{productRepo: repositories.NewProductRepo(gormDB)}
+	res, err := s.toOrderResponses(t.Context(), []db.Order{{
+		ID: uuid.New(),
+		Products: []*db.OrderProduct{
+			{ProductID: "1", Quantity: 1, Name: "Waffle", UnitPrice: money.MustParse("6.50", money.USD)},
+			{ProductID: "2", Quantity: 2, Name: "Brownie", UnitPrice: money.MustParse("5.00", money.USD)},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res[0].Items) != 2 || len(res[0].Products) != 2 {
+		t.Fatalf("expected every ordered product, got %+v", res[0])
+	}
+	if p := res[0].Products[1]; p.ID != "2" || p.Name != "Brownie" || p.Price.Decimal() != "5.00" || p.Category != "" {
+		t.Errorf("expected the ordered snapshot of the missing product, got %+v", p)
+	}
+}
+
+func TestToOrderResponses_RendersOrderedNameAndPrice(t *testing.T) {
+	gormDB, sqlMock := testutil.NewMockDB(t)
+	sqlMock.ExpectQuery(`SELECT \* FROM "products" WHERE id IN \(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(1, "Belgian Waffle", "8.00", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	s := OrderService{productRepo: repositories.NewProductRepo(gormDB)}
+	res, err := s.toOrderResponses(t.Context(), []db.Order{{
+		ID: uuid.New(),
+		Products: []*db.OrderProduct{
+			{ProductID: "1", Quantity: 1, Name: "Waffle", UnitPrice: money.MustParse("6.50", money.USD)},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := res[0].Products[0]; p.Name != "Waffle" || p.Price.Decimal() != "6.50" || p.Category != "Waffle" {
+		t.Errorf("expected the product as ordered, got %+v", p)
+	}
+}
+
+func TestCreate_NoRedemptionWithoutPromotion(t *testing.T) {
+	gormDB, sqlMock := testutil.NewMockDB(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT \* FROM "products" WHERE id IN \(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(1, "Waffle", "6.50", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+	sqlMock.ExpectQuery(`SELECT \* FROM "promotions"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectQuery(`INSERT INTO "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	sqlMock.ExpectQuery(`INSERT INTO "order_products"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectQuery(`INSERT INTO "order_status_history"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	validator := new(MockCouponValidator)
+	validator.On("Validate", "HAPPYHRS").Return(true, nil)
+	s := NewOrderService(repositories.NewUnitOfWork(gormDB), validator,
+		repositories.NewOrderRepo(gormDB), repositories.NewProductRepo(gormDB))
+
+	if _, err := s.Create(t.Context(), &request.OrderRequest{
+		CouponCode: "HAPPYHRS",
+		Items:      []request.Item{{ProductID: "1", Quantity: 1}},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
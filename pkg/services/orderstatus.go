@@ -0,0 +1,35 @@
+package services
+
+// Order statuses, an order starts as pending.
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusPreparing = "preparing"
+	OrderStatusReady     = "ready"
+	OrderStatusCompleted = "completed"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// orderStatusTransitions lists the statuses an order may move to from each status.
+// Completed orders can only be refunded, refunded orders are final.
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusPreparing, OrderStatusCancelled},
+	OrderStatusPreparing: {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:     {OrderStatusCompleted, OrderStatusCancelled},
+	OrderStatusCompleted: {OrderStatusRefunded},
+	OrderStatusCancelled: {OrderStatusRefunded},
+	OrderStatusRefunded:  {},
+}
+
+// canTransition reports whether an order may move from one status to another.
+func canTransition(from, to string) bool {
+	for _, s := range orderStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,30 @@
+package services
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		expected bool
+	}{
+		{OrderStatusPending, OrderStatusConfirmed, true},
+		{OrderStatusPending, OrderStatusCancelled, true},
+		{OrderStatusPending, OrderStatusCompleted, false},
+		{OrderStatusConfirmed, OrderStatusPreparing, true},
+		{OrderStatusPreparing, OrderStatusReady, true},
+		{OrderStatusReady, OrderStatusCompleted, true},
+		{OrderStatusReady, OrderStatusPending, false},
+		{OrderStatusCompleted, OrderStatusRefunded, true},
+		{OrderStatusCompleted, OrderStatusCancelled, false},
+		{OrderStatusCancelled, OrderStatusRefunded, true},
+		{OrderStatusCancelled, OrderStatusConfirmed, false},
+		{OrderStatusRefunded, OrderStatusPending, false},
+		{OrderStatusPending, OrderStatusPending, false},
+		{"unknown", OrderStatusConfirmed, false},
+	}
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.expected {
+			t.Errorf("canTransition(%s, %s) = %v, expected %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
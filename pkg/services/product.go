@@ -2,59 +2,147 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"strconv"
+	"time"
 
+	"github.com/malakagl/kart-challenge/internal/tracing"
 	"github.com/malakagl/kart-challenge/pkg/errors"
 	"github.com/malakagl/kart-challenge/pkg/log"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
 	"github.com/malakagl/kart-challenge/pkg/models/dto/response"
+	"github.com/malakagl/kart-challenge/pkg/money"
 	"github.com/malakagl/kart-challenge/pkg/repositories"
 )
 
+const (
+	defaultProductPageSize   = 50
+	defaultProductSearchSize = 20
+)
+
+// maxProductPrice is the largest price the decimal(10,2) price column holds.
+var maxProductPrice = money.MustParse("99999999.99", money.DefaultCurrency)
+
 type IProductService interface {
-	FindAll(ctx context.Context) (*response.ProductsResponse, error)
+	FindAll(ctx context.Context, req *request.ProductListRequest) (*response.ProductsResponse, *response.Pagination, error)
 	FindByID(ctx context.Context, id uint) (*response.ProductResponse, error)
+	Search(ctx context.Context, req *request.ProductSearchRequest) (*response.ProductSearchResponse, error)
+	Create(ctx context.Context, req *request.ProductRequest) (*response.ProductResponse, error)
+	Replace(ctx context.Context, id uint, req *request.ProductRequest) (*response.ProductResponse, error)
+	Update(ctx context.Context, id uint, req *request.ProductPatchRequest) (*response.ProductResponse, error)
+	Delete(ctx context.Context, id uint) (*response.ProductResponse, error)
+}
+
+// ProductCache holds catalog reads until the next catalog write, cache.LRU implements it.
+// Reads are stored with the generation read before loading them, so a read racing a write
+// is dropped instead of outliving the purge.
+type ProductCache interface {
+	Get(key string) (any, bool)
+	Generation() uint64
+	SetIfGeneration(key string, value any, gen uint64) bool
+	Purge()
+}
+
+// productPage is a cached FindAll result
+type productPage struct {
+	products *response.ProductsResponse
+	page     *response.Pagination
 }
 
 type ProductService struct {
-	repo repositories.ProductRepo
+	uow        repositories.UnitOfWork
+	repo       repositories.ProductRepo
+	categories repositories.CategoryRepo
+	cache      ProductCache // nil when reads are not cached
 }
 
-func NewProductService(r repositories.ProductRepo) ProductService {
-	return ProductService{repo: r}
+func NewProductService(u repositories.UnitOfWork, r repositories.ProductRepo, c repositories.CategoryRepo, pc ProductCache) ProductService {
+	return ProductService{uow: u, repo: r, categories: c, cache: pc}
 }
 
-func (s *ProductService) FindAll(ctx context.Context) (*response.ProductsResponse, error) {
-	res, err := s.repo.FindAll(ctx)
+func (s *ProductService) FindAll(ctx context.Context, req *request.ProductListRequest) (*response.ProductsResponse, *response.Pagination, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.FindAll")
+	defer span.End()
+
+	key := productListKey(req)
+	v, gen := s.cached(key)
+	if v, ok := v.(productPage); ok {
+		return v.products, v.page, nil
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultProductPageSize
+	}
+	sort := req.Sort
+	if sort == "" {
+		sort = repositories.ProductSortCreatedAt
+	}
+
+	filter := repositories.ProductFilter{
+		CategorySlug: categorySlug(req.Category),
+		MinPrice:     req.MinPrice,
+		MaxPrice:     req.MaxPrice,
+		Query:        req.Query,
+		Sort:         sort,
+		Desc:         req.Order == "desc",
+		Limit:        limit + 1, // one extra row tells whether there is a next page
+	}
+	if req.Category != "" && filter.CategorySlug == "" {
+		return nil, nil, errors.ErrInvalidCategoryName
+	}
+	if req.CategorySlug != "" {
+		category, err := s.categories.FindActiveBySlug(ctx, req.CategorySlug)
+		if err != nil {
+			log.WithCtx(ctx).Error().Msgf("Error fetching category %s: %v", req.CategorySlug, err)
+			return nil, nil, err
+		}
+		filter.CategoryID = category.ID
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeProductCursor(req.Cursor)
+		if err != nil || !cursor.Valid(filter) {
+			log.WithCtx(ctx).Error().Msgf("Invalid product cursor %s: %v", req.Cursor, err)
+			return nil, nil, errors.ErrInvalidCursor
+		}
+		filter.After = cursor
+	}
+
+	res, err := s.repo.FindAll(ctx, filter)
 	if err != nil {
 		log.WithCtx(ctx).Error().Msgf("findAll failed with error: %v", err)
-		return nil, errors.ErrProductNotFound
+		return nil, nil, err
 	}
 
-	if res == nil {
-		log.WithCtx(ctx).Error().Msg("findAll returned 0 elements")
-		return nil, errors.ErrProductNotFound
+	page := &response.Pagination{Limit: limit}
+	if len(res) > limit {
+		res = res[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeProductCursor(repositories.NewProductCursor(&res[limit-1], filter))
 	}
 
 	products := make([]response.Product, len(res))
-	for i, p := range res {
-		products[i] = response.Product{
-			ID:       strconv.FormatUint(uint64(p.ID), 10),
-			Name:     p.Name,
-			Price:    p.Price,
-			Category: p.Category,
-			Image: response.ProductImage{
-				Thumbnail: p.Image.Thumbnail,
-				Mobile:    p.Image.Mobile,
-				Tablet:    p.Image.Tablet,
-				Desktop:   p.Image.Desktop,
-			},
-		}
+	for i := range res {
+		products[i] = toProductResponse(&res[i])
 	}
 
-	return &response.ProductsResponse{Products: products}, nil
+	list := &response.ProductsResponse{Products: products, LastModified: s.lastModified(ctx)}
+	s.store(key, productPage{products: list, page: page}, gen)
+	return list, page, nil
 }
 
 func (s *ProductService) FindByID(ctx context.Context, id uint) (*response.ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.FindByID")
+	defer span.End()
+
+	key := "product:" + strconv.FormatUint(uint64(id), 10)
+	v, gen := s.cached(key)
+	if v, ok := v.(*response.ProductResponse); ok {
+		return v, nil
+	}
+
 	res, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		log.WithCtx(ctx).Error().Msgf("findAll failed with error: %v", err)
@@ -66,16 +154,300 @@ func (s *ProductService) FindByID(ctx context.Context, id uint) (*response.Produ
 		return nil, errors.ErrProductNotFound
 	}
 
-	return &response.ProductResponse{
-		ID:       strconv.FormatUint(uint64(res.ID), 10),
-		Name:     res.Name,
-		Price:    res.Price,
-		Category: res.Category,
+	product := response.ProductResponse(toProductResponse(res))
+	s.store(key, &product, gen)
+	return &product, nil
+}
+
+// Search finds products by name and category, best matches first.
+func (s *ProductService) Search(ctx context.Context, req *request.ProductSearchRequest) (*response.ProductSearchResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.Search")
+	defer span.End()
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultProductSearchSize
+	}
+
+	matches, err := s.repo.Search(ctx, repositories.ProductSearch{Query: req.Query, Fuzzy: req.Fuzzy, Limit: limit})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error searching products: %v", err)
+		return nil, err
+	}
+
+	results := make([]response.ProductSearchResult, 0, len(matches))
+	if len(matches) == 0 {
+		return &response.ProductSearchResponse{Results: results}, nil
+	}
+
+	ids := make([]uint, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ProductID
+	}
+	products, err := s.repo.FindByIDs(ctx, ids)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching matched products: %v", err)
+		return nil, err
+	}
+
+	byID := make(map[uint]*db.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+	for _, m := range matches {
+		p, ok := byID[m.ProductID]
+		if !ok { // deleted since it was matched
+			continue
+		}
+
+		results = append(results, response.ProductSearchResult{
+			Product:   toProductResponse(p),
+			Rank:      m.Rank,
+			Highlight: response.ProductHighlight{Name: m.NameHighlight, Category: m.CategoryHighlight},
+		})
+	}
+
+	return &response.ProductSearchResponse{Results: results}, nil
+}
+
+// Create adds a product to the catalog together with its image.
+func (s *ProductService) Create(ctx context.Context, req *request.ProductRequest) (*response.ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.Create")
+	defer span.End()
+
+	if !validProductPrice(*req.Price) {
+		return nil, errors.ErrInvalidProductPrice
+	}
+
+	product := db.Product{}
+	applyProductRequest(&product, req)
+	err := s.uow.Do(ctx, func(repos repositories.Repositories) error {
+		if err := assignCategory(ctx, repos.Categories, &product); err != nil {
+			return err
+		}
+
+		return repos.Products.Create(ctx, &product)
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error creating product: %v", err)
+		return nil, err
+	}
+	s.invalidate()
+
+	res := response.ProductResponse(toProductResponse(&product))
+	return &res, nil
+}
+
+// Replace overwrites every field of the product and its image.
+func (s *ProductService) Replace(ctx context.Context, id uint, req *request.ProductRequest) (*response.ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.Replace")
+	defer span.End()
+
+	if !validProductPrice(*req.Price) {
+		return nil, errors.ErrInvalidProductPrice
+	}
+
+	return s.update(ctx, id, func(p *db.Product) {
+		applyProductRequest(p, req)
+	})
+}
+
+// Update changes the fields set in the request and keeps the others.
+func (s *ProductService) Update(ctx context.Context, id uint, req *request.ProductPatchRequest) (*response.ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.Update")
+	defer span.End()
+
+	if req.Price != nil && !validProductPrice(*req.Price) {
+		return nil, errors.ErrInvalidProductPrice
+	}
+
+	return s.update(ctx, id, func(p *db.Product) {
+		applyProductPatch(p, req)
+	})
+}
+
+// Delete removes the product from the catalog. Orders placed with it keep showing it.
+func (s *ProductService) Delete(ctx context.Context, id uint) (*response.ProductResponse, error) {
+	ctx, span := tracing.Start(ctx, "ProductService.Delete")
+	defer span.End()
+
+	var product *db.Product
+	err := s.uow.Do(ctx, func(repos repositories.Repositories) error {
+		var err error
+		if product, err = repos.Products.FindByIDForUpdate(ctx, id); err != nil {
+			return err
+		}
+
+		return repos.Products.Delete(ctx, id)
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error deleting product %d: %v", id, err)
+		return nil, err
+	}
+	s.invalidate()
+
+	res := response.ProductResponse(toProductResponse(product))
+	return &res, nil
+}
+
+// update applies change to the locked product and saves it in one transaction.
+func (s *ProductService) update(ctx context.Context, id uint, change func(*db.Product)) (*response.ProductResponse, error) {
+	var product *db.Product
+	err := s.uow.Do(ctx, func(repos repositories.Repositories) error {
+		var err error
+		if product, err = repos.Products.FindByIDForUpdate(ctx, id); err != nil {
+			return err
+		}
+
+		category := product.Category
+		change(product)
+		if product.Category != category {
+			if err := assignCategory(ctx, repos.Categories, product); err != nil {
+				return err
+			}
+		}
+
+		return repos.Products.Save(ctx, product)
+	})
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error updating product %d: %v", id, err)
+		return nil, err
+	}
+	s.invalidate()
+
+	res := response.ProductResponse(toProductResponse(product))
+	return &res, nil
+}
+
+// lastModified is when the catalog last changed, zero when it could not be read so no
+// Last-Modified header is sent.
+func (s *ProductService) lastModified(ctx context.Context) time.Time {
+	last, err := s.repo.LastModified(ctx)
+	if err != nil {
+		log.WithCtx(ctx).Error().Msgf("Error fetching catalog modification time: %v", err)
+		return time.Time{}
+	}
+
+	return last
+}
+
+// cached returns the cached read of key, and the cache generation to store a fresh read with.
+func (s *ProductService) cached(key string) (any, uint64) {
+	if s.cache == nil {
+		return nil, 0
+	}
+
+	gen := s.cache.Generation()
+	v, _ := s.cache.Get(key)
+	return v, gen
+}
+
+// store caches a read unless a catalog write cleared the cache since gen, the read may
+// have been loaded before the write.
+func (s *ProductService) store(key string, v any, gen uint64) {
+	if s.cache != nil {
+		s.cache.SetIfGeneration(key, v, gen)
+	}
+}
+
+// invalidate drops every cached read after a catalog write, a change to one product can
+// move it in or out of any list.
+func (s *ProductService) invalidate() {
+	if s.cache != nil {
+		s.cache.Purge()
+	}
+}
+
+// productListKey identifies a FindAll request in the cache.
+func productListKey(req *request.ProductListRequest) string {
+	b, _ := json.Marshal(req)
+	return "products:" + string(b)
+}
+
+// assignCategory links the product to the existing category named by p.Category, so a
+// misspelt name is rejected instead of creating a new category. Names differing only in
+// case or punctuation share a slug and resolve to the same category, whose name the
+// product takes.
+func assignCategory(ctx context.Context, categories repositories.CategoryRepo, p *db.Product) error {
+	category, err := categories.FindBySlug(ctx, categorySlug(p.Category))
+	if err != nil {
+		return err
+	}
+
+	p.CategoryID, p.Category = category.ID, category.Name
+	return nil
+}
+
+func applyProductRequest(p *db.Product, req *request.ProductRequest) {
+	p.Name = req.Name
+	p.Price = *req.Price
+	p.Category = req.Category
+	p.Image.Thumbnail = req.Image.Thumbnail
+	p.Image.Mobile = req.Image.Mobile
+	p.Image.Tablet = req.Image.Tablet
+	p.Image.Desktop = req.Image.Desktop
+}
+
+func applyProductPatch(p *db.Product, req *request.ProductPatchRequest) {
+	setIfPresent(&p.Name, req.Name)
+	if req.Price != nil {
+		p.Price = *req.Price
+	}
+	setIfPresent(&p.Category, req.Category)
+	if img := req.Image; img != nil {
+		setIfPresent(&p.Image.Thumbnail, img.Thumbnail)
+		setIfPresent(&p.Image.Mobile, img.Mobile)
+		setIfPresent(&p.Image.Tablet, img.Tablet)
+		setIfPresent(&p.Image.Desktop, img.Desktop)
+	}
+}
+
+// validProductPrice tells whether the price is neither negative nor too large to be stored.
+func validProductPrice(price money.Money) bool {
+	if price.IsNegative() {
+		return false
+	}
+	cmp, err := price.Cmp(maxProductPrice)
+	return err == nil && cmp <= 0
+}
+
+func setIfPresent(dst *string, v *string) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func toProductResponse(p *db.Product) response.Product {
+	return response.Product{
+		ID:        strconv.FormatUint(uint64(p.ID), 10),
+		Name:      p.Name,
+		Price:     p.Price,
+		Category:  p.Category,
+		UpdatedAt: p.UpdatedAt,
 		Image: response.ProductImage{
-			Thumbnail: res.Image.Thumbnail,
-			Mobile:    res.Image.Mobile,
-			Tablet:    res.Image.Tablet,
-			Desktop:   res.Image.Desktop,
+			Thumbnail: p.Image.Thumbnail,
+			Mobile:    p.Image.Mobile,
+			Tablet:    p.Image.Tablet,
+			Desktop:   p.Image.Desktop,
 		},
-	}, nil
+	}
+}
+
+func encodeProductCursor(c repositories.ProductCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeProductCursor(s string) (*repositories.ProductCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var c repositories.ProductCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
 }
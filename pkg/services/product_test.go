@@ -0,0 +1,382 @@
+package services
+
+import (
+	errors2 "errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/malakagl/kart-challenge/internal/cache"
+	"github.com/malakagl/kart-challenge/internal/testutil"
+	"github.com/malakagl/kart-challenge/pkg/errors"
+	"github.com/malakagl/kart-challenge/pkg/models/db"
+	"github.com/malakagl/kart-challenge/pkg/models/dto/request"
+	"github.com/malakagl/kart-challenge/pkg/money"
+	"github.com/malakagl/kart-challenge/pkg/repositories"
+)
+
+func newProductService(t *testing.T) (ProductService, sqlmock.Sqlmock) {
+	return newCachedProductService(t, nil)
+}
+
+func newCachedProductService(t *testing.T, c ProductCache) (ProductService, sqlmock.Sqlmock) {
+	t.Helper()
+	gormDB, sqlMock := testutil.NewMockDB(t)
+
+	return NewProductService(repositories.NewUnitOfWork(gormDB), repositories.NewProductRepo(gormDB),
+		repositories.NewCategoryRepo(gormDB), c), sqlMock
+}
+
+func TestApplyProductPatch(t *testing.T) {
+	p := db.Product{Name: "Waffle", Category: "Waffle", Price: money.MustParse("6.50", money.USD),
+		Image: db.ProductImage{Thumbnail: "t.jpg", Mobile: "m.jpg"}}
+	name, mobile, price := "Belgian Waffle", "m2.jpg", money.MustParse("7.00", money.USD)
+
+	applyProductPatch(&p, &request.ProductPatchRequest{
+		Name:  &name,
+		Price: &price,
+		Image: &request.ProductImagePatchRequest{Mobile: &mobile},
+	})
+	if p.Name != name || p.Category != "Waffle" || p.Price.Decimal() != "7.00" {
+		t.Errorf("unexpected product after patch %+v", p)
+	}
+	if p.Image.Mobile != mobile || p.Image.Thumbnail != "t.jpg" {
+		t.Errorf("unexpected image after patch %+v", p.Image)
+	}
+}
+
+func TestProductDelete_SoftDeletes(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE id = $1 AND "products"."deleted_at" IS NULL`) + `.* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(2, "Waffle", "6.50", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images" WHERE product_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "thumbnail"}).AddRow(1, 2, "t.jpg"))
+	sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "products" SET "deleted_at"=$1 WHERE "products"."id" = $2 AND "products"."deleted_at" IS NULL`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	res, err := s.Delete(t.Context(), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ID != "2" || res.Image.Thumbnail != "t.jpg" {
+		t.Errorf("expected the deleted product in the response, got %+v", res)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductDelete_NotFound(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT \* FROM "products"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectRollback()
+
+	if _, err := s.Delete(t.Context(), 9); !errors2.Is(err, errors.ErrProductNotFound) {
+		t.Errorf("expected product not found, got %v", err)
+	}
+}
+
+func TestProductCreate_RejectsInvalidPrice(t *testing.T) {
+	s, _ := newProductService(t)
+	for _, p := range []string{"-1.00", "100000000.00"} {
+		price := money.MustParse(p, money.USD)
+		_, err := s.Create(t.Context(), &request.ProductRequest{Name: "Waffle", Price: &price, Category: "Waffle"})
+		if !errors2.Is(err, errors.ErrInvalidProductPrice) {
+			t.Errorf("expected invalid product price for %s, got %v", p, err)
+		}
+	}
+}
+
+func TestProductUpdate_RejectsTooLargePrice(t *testing.T) {
+	s, _ := newProductService(t)
+	price := money.MustParse("100000000.00", money.USD)
+
+	_, err := s.Update(t.Context(), 1, &request.ProductPatchRequest{Price: &price})
+	if !errors2.Is(err, errors.ErrInvalidProductPrice) {
+		t.Errorf("expected invalid product price, got %v", err)
+	}
+}
+
+func TestProductFindAll_Pagination(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`ORDER BY price DESC,id DESC LIMIT $1`)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).
+			AddRow(3, "Cake", "9.00", "Cake").AddRow(1, "Waffle", "6.50", "Waffle").AddRow(2, "Brownie", "5.00", "Brownie"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	res, page, err := s.FindAll(t.Context(), &request.ProductListRequest{Sort: "price", Order: "desc", Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Products) != 2 || !page.HasMore || page.Limit != 2 {
+		t.Fatalf("expected a full page with more to come, got %d products and %+v", len(res.Products), page)
+	}
+
+	cursor, err := decodeProductCursor(page.NextCursor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cursor.Sort != "price" || !cursor.Desc || cursor.Value != "6.50" || cursor.ID != 1 {
+		t.Errorf("expected the cursor to point after the last product, got %+v", cursor)
+	}
+
+	if _, _, err := s.FindAll(t.Context(), &request.ProductListRequest{Sort: "name", Cursor: page.NextCursor}); !errors2.Is(err, errors.ErrInvalidCursor) {
+		t.Errorf("expected a cursor of another sort to be rejected, got %v", err)
+	}
+	if _, _, err := s.FindAll(t.Context(), &request.ProductListRequest{Cursor: "%%%"}); !errors2.Is(err, errors.ErrInvalidCursor) {
+		t.Errorf("expected a malformed cursor to be rejected, got %v", err)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductFindAll_Empty(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE category_id = (SELECT id FROM categories WHERE slug = $1)`)).
+		WithArgs("soup-of-the-day", 51).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	res, page, err := s.FindAll(t.Context(), &request.ProductListRequest{Category: "Soup of the Day!"})
+	if err != nil {
+		t.Fatalf("expected no error for an empty page, got %v", err)
+	}
+	if len(res.Products) != 0 || page.HasMore || page.Limit != defaultProductPageSize {
+		t.Errorf("unexpected empty page %+v %+v", res, page)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductFindAll_RejectsCategoryWithoutSlug(t *testing.T) {
+	s, _ := newProductService(t)
+
+	_, _, err := s.FindAll(t.Context(), &request.ProductListRequest{Category: "???"})
+	if !errors2.Is(err, errors.ErrInvalidCategoryName) {
+		t.Errorf("expected invalid category name, got %v", err)
+	}
+}
+
+func TestProductFindAll_CategorySlug(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE slug = $1 AND active`)).WithArgs("waffle", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug"}).AddRow(4, "Waffle", "waffle"))
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE category_id = $1`)).WithArgs(4, 51).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category", "category_id"}).AddRow(1, "Waffle", "6.50", "Waffle", 4))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	res, _, err := s.FindAll(t.Context(), &request.ProductListRequest{CategorySlug: "waffle"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Products) != 1 || res.Products[0].Category != "Waffle" {
+		t.Errorf("expected the products of the category, got %+v", res.Products)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductFindAll_UnknownCategorySlug(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectQuery(`SELECT \* FROM "categories"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, _, err := s.FindAll(t.Context(), &request.ProductListRequest{CategorySlug: "soup"})
+	if !errors2.Is(err, errors.ErrCategoryNotFound) {
+		t.Errorf("expected category not found, got %v", err)
+	}
+}
+
+func TestProductCreate_AssignsCategory(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	price := money.MustParse("6.50", money.USD)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "categories" WHERE slug = $1`)).WithArgs("waffle", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug"}).AddRow(4, "Waffle", "waffle"))
+	sqlMock.ExpectQuery(`INSERT INTO "products"`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Waffle", 4,
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	sqlMock.ExpectQuery(`INSERT INTO "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	sqlMock.ExpectCommit()
+
+	res, err := s.Create(t.Context(), &request.ProductRequest{Name: "Waffle", Price: &price, Category: " WAFFLE ",
+		Image: request.ProductImageRequest{Thumbnail: "t.jpg", Mobile: "m.jpg", Tablet: "t.jpg", Desktop: "d.jpg"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ID != "7" || res.Category != "Waffle" {
+		t.Errorf("unexpected product %+v", res)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductCreate_UnknownCategory(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	price := money.MustParse("6.50", money.USD)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT \* FROM "categories"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	sqlMock.ExpectRollback()
+
+	_, err := s.Create(t.Context(), &request.ProductRequest{Name: "Waffle", Price: &price, Category: "Wafle"})
+	if !errors2.Is(err, errors.ErrCategoryNotFound) {
+		t.Errorf("expected category not found, got %v", err)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductUpdate_KeepsCategoryWhenUnchanged(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	price := money.MustParse("7.00", money.USD)
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category", "category_id"}).AddRow(2, "Waffle", "6.50", "Waffle", 4))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}).AddRow(1, 2))
+	sqlMock.ExpectExec(`UPDATE "products"`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Waffle", 4,
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectExec(`UPDATE "product_images"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+
+	res, err := s.Update(t.Context(), 2, &request.ProductPatchRequest{Price: &price})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Price.Decimal() != "7.00" || res.Category != "Waffle" {
+		t.Errorf("unexpected product %+v", res)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductSearch_KeepsRankOrder(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectQuery(`SELECT id AS product_id`).WithArgs("waf:*", "waf:*", "waf:*", sqlmock.AnyArg(), "waf:*", sqlmock.AnyArg(), "waf:*", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "rank", "name_highlight", "category_highlight"}).
+			AddRow(2, 0.9, "<mark>Waffle</mark>", "<mark>Waffle</mark>").
+			AddRow(5, 0.4, "Chicken <mark>Waffle</mark>", "Main").
+			AddRow(8, 0.1, "Gone", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "products" WHERE id IN`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).
+			AddRow(5, "Chicken Waffle", "13.30", "Main").AddRow(2, "Waffle", "6.50", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	res, err := s.Search(t.Context(), &request.ProductSearchRequest{Query: "waf"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Results) != 2 || res.Results[0].ID != "2" || res.Results[1].ID != "5" {
+		t.Fatalf("expected the found products best match first, got %+v", res.Results)
+	}
+	if res.Results[1].Highlight.Name != "Chicken <mark>Waffle</mark>" || res.Results[1].Rank != 0.4 {
+		t.Errorf("unexpected result %+v", res.Results[1])
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductSearch_NoMatches(t *testing.T) {
+	s, sqlMock := newProductService(t)
+	sqlMock.ExpectQuery(`SELECT id AS product_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "rank", "name_highlight", "category_highlight"}))
+
+	res, err := s.Search(t.Context(), &request.ProductSearchRequest{Query: "soup", Limit: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Results == nil || len(res.Results) != 0 {
+		t.Errorf("expected an empty result list, got %+v", res.Results)
+	}
+}
+
+func TestProductFindByID_Cached(t *testing.T) {
+	s, sqlMock := newCachedProductService(t, cache.NewLRU[string, any](10, time.Minute))
+	sqlMock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(2, "Waffle", "6.50", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	for range 3 {
+		res, err := s.FindByID(t.Context(), 2)
+		if err != nil || res.ID != "2" {
+			t.Fatalf("unexpected result %+v %v", res, err)
+		}
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProductFindAll_CachedUntilWrite(t *testing.T) {
+	modified := time.Date(2025, 3, 4, 10, 30, 0, 0, time.UTC)
+	s, sqlMock := newCachedProductService(t, cache.NewLRU[string, any](10, time.Minute))
+	expectList := func() {
+		sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "products" WHERE category_id = (SELECT id FROM categories WHERE slug = $1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(2, "Waffle", "6.50", "Waffle"))
+		sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+		sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT MAX(GREATEST(updated_at, deleted_at)) FROM "products"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(modified))
+	}
+	req := &request.ProductListRequest{Category: "Waffle"}
+
+	expectList()
+	for range 2 {
+		res, _, err := s.FindAll(t.Context(), req)
+		if err != nil || len(res.Products) != 1 || !res.LastModified.Equal(modified) {
+			t.Fatalf("unexpected result %+v %v", res, err)
+		}
+	}
+
+	// a write clears the cache, the next read goes to the database again
+	sqlMock.ExpectBegin()
+	sqlMock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(2, "Waffle", "6.50", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+	sqlMock.ExpectExec(`UPDATE "products" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	sqlMock.ExpectCommit()
+	if _, err := s.Delete(t.Context(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectList()
+	if _, _, err := s.FindAll(t.Context(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sqlMock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// purgingCache is purged on every lookup, as if a write finished while the read was loaded.
+type purgingCache struct {
+	*cache.LRU[string, any]
+}
+
+func (c purgingCache) Get(key string) (any, bool) {
+	defer c.Purge()
+	return c.LRU.Get(key)
+}
+
+func TestProductFindByID_DropsReadRacingAWrite(t *testing.T) {
+	c := purgingCache{cache.NewLRU[string, any](10, time.Minute)}
+	s, sqlMock := newCachedProductService(t, c)
+	sqlMock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "category"}).AddRow(2, "Waffle", "6.50", "Waffle"))
+	sqlMock.ExpectQuery(`SELECT \* FROM "product_images"`).WillReturnRows(sqlmock.NewRows([]string{"id", "product_id"}))
+
+	if _, err := s.FindByID(t.Context(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected the read loaded before the write not to be cached, got %d entries", c.Len())
+	}
+}
@@ -1,9 +1,12 @@
 package util
 
 import (
+	"context"
 	"path/filepath"
 	"runtime"
 	"strconv"
+
+	"github.com/malakagl/kart-challenge/pkg/constants"
 )
 
 // RelativeFilePath constructs a relative file path based on the current file's location.
@@ -24,3 +27,13 @@ func StringToUint(s string) (uint, error) {
 
 	return uint(t), nil
 }
+
+// ClientID identifies the API client of the request, anonymous when it is not
+// authenticated.
+func ClientID(ctx context.Context) string {
+	if id, ok := ctx.Value(constants.ClientIDKey).(string); ok {
+		return id
+	}
+
+	return "anonymous"
+}